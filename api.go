@@ -1,6 +1,7 @@
 package plugged
 
 import (
+	"fmt"
 	"os"
 	"syscall"
 )
@@ -10,11 +11,50 @@ func Gateway(name, description string, args []string) {
 	gateway := &GatewayT{
 		Stdin:       os.Stdin,
 		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
 		Home:        os.Getenv("HOME"),
 		Name:        name,
 		Description: description,
 		ExecFn:      syscall.Exec,
 	}
 
-	gateway.Run(args)
+	// SIGINT cancels the command in flight, see interruptsT.
+	gateway.interrupts = newInterrupts()
+	ctx, stop := gateway.interruptible()
+	defer stop()
+
+	// Plugins are dispatched without opening the registry, when possible.
+	if ran, err := gateway.RunIndexed(args); ran {
+		if err != nil {
+			exit(err)
+		}
+		return
+	}
+
+	// Doctor runs without the registry, to report why it can not be opened.
+	if err := gateway.ConnectContext(ctx); err != nil && !(len(args) > 1 && args[1] == "--plugged-doctor") {
+		exit(err)
+	}
+
+	err := gateway.RunContext(ctx, args)
+	gateway.Disconnect()
+
+	if err != nil {
+		exit(err)
+	}
+}
+
+// exit terminates the gateway after err: with exit status of plugin that
+// ran in-process, eg. a WebAssembly one, as if it was executed, or with
+// status 1 after printing err, unless it was reported already.
+func exit(err error) {
+	switch err := err.(type) {
+	case *ExitError:
+		os.Exit(err.Code)
+	case renderedError:
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+	os.Exit(1)
 }
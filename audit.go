@@ -0,0 +1,294 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultAuditRetention is how long plugin invocations are kept in the audit
+// log, unless GatewayT.AuditRetention says otherwise.
+const defaultAuditRetention = 30 * 24 * time.Hour
+
+// slowestInvocations is how many of the slowest invocations --plugged-stats
+// reports.
+const slowestInvocations = 5
+
+// invocationT is an entry of the audit log. ExitCode and Duration are known
+// only when plugin runs as a child process: by default the gateway process
+// is replaced by the plugin.
+type invocationT struct {
+	Time     time.Time     `json:"Time"`
+	Plugin   string        `json:"Plugin"`
+	Version  string        `json:"Version,omitempty"`
+	Args     []string      `json:"Args"`
+	ExitCode *int          `json:"ExitCode,omitempty"`
+	Duration time.Duration `json:"Duration,omitempty"`
+
+	key string
+}
+
+// pluginStatsT is usage statistics of one plugin. Completed is the number of
+// runs with known exit code, that FailureRate and AverageTime are based on.
+type pluginStatsT struct {
+	Plugin      string        `json:"Plugin"`
+	Runs        int           `json:"Runs"`
+	Completed   int           `json:"Completed"`
+	Failures    int           `json:"Failures"`
+	FailureRate float64       `json:"FailureRate"`
+	AverageTime time.Duration `json:"AverageTime"`
+	MaxTime     time.Duration `json:"MaxTime"`
+
+	totalTime time.Duration
+}
+
+// statsT is the report of --plugged-stats.
+type statsT struct {
+	Plugins []*pluginStatsT `json:"Plugins"`
+	Slowest []*invocationT  `json:"Slowest"`
+}
+
+func (g *GatewayT) auditRetention() time.Duration {
+	if g.AuditRetention == 0 {
+		return defaultAuditRetention
+	}
+
+	return g.AuditRetention
+}
+
+// startInvocation records plugin invocation in the audit log, removing
+// entries older than the retention period. Plugin should run regardless of
+// the audit log, so failures are only reported as warnings.
+func (g *GatewayT) startInvocation(p *pluginT, args []string) *invocationT {
+	invocation := g.newInvocation(p, args)
+	if invocation == nil {
+		return nil
+	}
+
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("invocations")
+		if err != nil {
+			return err
+		}
+
+		if err := pruneInvocations(b, invocation.Time.Add(-g.auditRetention())); err != nil {
+			return err
+		}
+
+		return invocation.save(b)
+	})
+
+	if err != nil {
+		fmt.Fprintf(g.stderr(), "[WARNING] Unable to record invocation of '%s' - %s\n", p.Name, err)
+		return nil
+	}
+
+	return invocation
+}
+
+// newInvocation returns audit log entry for plugin run starting now, or nil
+// when the audit log is turned off.
+func (g *GatewayT) newInvocation(p *pluginT, args []string) *invocationT {
+	if g.auditRetention() < 0 {
+		return nil
+	}
+
+	return &invocationT{
+		Time:    time.Now().UTC(),
+		Plugin:  p.Name,
+		Version: p.Version,
+		Args:    g.redactArgs(p, args),
+	}
+}
+
+// finishInvocation records exit code and duration of a plugin run as a
+// child process.
+func (g *GatewayT) finishInvocation(invocation *invocationT, runErr error) {
+	if invocation == nil {
+		return
+	}
+
+	invocation.complete(runErr)
+
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("invocations")
+		if err != nil {
+			return err
+		}
+
+		return invocation.save(b)
+	})
+
+	if err != nil {
+		fmt.Fprintf(g.stderr(), "[WARNING] Unable to record invocation of '%s' - %s\n", invocation.Plugin, err)
+	}
+}
+
+// complete sets exit code and duration of invocation. Errors other than
+// non-zero exit status mean the plugin could not be started at all, which is
+// recorded as status 127, like shells do.
+func (i *invocationT) complete(runErr error) {
+	code := 0
+	if exitErr, ok := runErr.(*ExitError); ok {
+		code = exitErr.Code
+	} else if runErr != nil {
+		code = 127
+	}
+
+	i.ExitCode = &code
+	i.Duration = time.Since(i.Time).Round(time.Millisecond)
+}
+
+// save stores invocation under a key that sorts in time order.
+func (i *invocationT) save(b Bucket) error {
+	if i.key == "" {
+		i.key = i.Time.UTC().Format("20060102T150405.000000000Z") + " " + i.Plugin
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal invocation %+v to json - %s", *i, err)
+	}
+
+	return b.Put([]byte(i.key), data)
+}
+
+func pruneInvocations(b Bucket, before time.Time) error {
+	expired := [][]byte{}
+
+	err := b.ForEach(func(key, value []byte) error {
+		invocation := &invocationT{}
+		if err := json.Unmarshal(value, invocation); err != nil || invocation.Time.Before(before) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range expired {
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Invocations returns the audit log, oldest entries first.
+func (g *GatewayT) Invocations() ([]*invocationT, error) {
+	invocations := []*invocationT{}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("invocations")
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(key, value []byte) error {
+			invocation := &invocationT{}
+			if err := json.Unmarshal(value, invocation); err != nil {
+				return fmt.Errorf("Unable to unmarshal invocation %s - %s", key, err)
+			}
+
+			invocations = append(invocations, invocation)
+			return nil
+		})
+	})
+
+	return invocations, err
+}
+
+func (g *GatewayT) statsAction(_ string, args []string) error {
+	if len(args) > 0 {
+		return g.showUsage("--plugged-stats")
+	}
+
+	invocations, err := g.Invocations()
+	if err != nil {
+		return err
+	}
+
+	// Redaction patterns might have changed since invocations were
+	// recorded.
+	for _, invocation := range invocations {
+		for i, arg := range invocation.Args {
+			invocation.Args[i] = g.redact(arg)
+		}
+	}
+
+	stats := usageStats(invocations)
+
+	if g.output == outputJSON {
+		return g.renderJSON(stats)
+	}
+
+	view := &statsReportView{Stats: stats}
+	return view.render(g.template("statsReport"), g.Stdout)
+}
+
+// usageStats summarizes the audit log: plugins are sorted by the number of
+// runs, and slowest invocations by their duration.
+func usageStats(invocations []*invocationT) *statsT {
+	byPlugin := map[string]*pluginStatsT{}
+	stats := &statsT{
+		Plugins: []*pluginStatsT{},
+		Slowest: []*invocationT{},
+	}
+
+	for _, invocation := range invocations {
+		s, ok := byPlugin[invocation.Plugin]
+		if !ok {
+			s = &pluginStatsT{Plugin: invocation.Plugin}
+			byPlugin[invocation.Plugin] = s
+			stats.Plugins = append(stats.Plugins, s)
+		}
+
+		s.Runs++
+		if invocation.ExitCode == nil {
+			continue
+		}
+
+		s.Completed++
+		if *invocation.ExitCode != 0 {
+			s.Failures++
+		}
+
+		s.totalTime += invocation.Duration
+		if invocation.Duration > s.MaxTime {
+			s.MaxTime = invocation.Duration
+		}
+
+		stats.Slowest = append(stats.Slowest, invocation)
+	}
+
+	for _, s := range stats.Plugins {
+		if s.Completed > 0 {
+			s.FailureRate = float64(s.Failures) / float64(s.Completed)
+			s.AverageTime = s.totalTime / time.Duration(s.Completed)
+		}
+	}
+
+	sort.Stable(pluginStatsByRuns(stats.Plugins))
+	sort.Stable(invocationsByDuration(stats.Slowest))
+
+	if len(stats.Slowest) > slowestInvocations {
+		stats.Slowest = stats.Slowest[:slowestInvocations]
+	}
+
+	return stats
+}
+
+type pluginStatsByRuns []*pluginStatsT
+
+func (s pluginStatsByRuns) Len() int           { return len(s) }
+func (s pluginStatsByRuns) Less(i, j int) bool { return s[i].Runs > s[j].Runs }
+func (s pluginStatsByRuns) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type invocationsByDuration []*invocationT
+
+func (s invocationsByDuration) Len() int           { return len(s) }
+func (s invocationsByDuration) Less(i, j int) bool { return s[i].Duration > s[j].Duration }
+func (s invocationsByDuration) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
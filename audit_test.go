@@ -0,0 +1,58 @@
+package plugged
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	g := &GatewayT{
+		Stderr: &bytes.Buffer{},
+		Store:  NewMemoryStore(),
+
+		AuditRetention: time.Hour,
+	}
+
+	expired := &invocationT{Time: time.Now().Add(-2 * time.Hour), Plugin: "find"}
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("invocations")
+		if err != nil {
+			return err
+		}
+		return expired.save(b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := &pluginT{Name: "deploy", metadataT: metadataT{Version: "1.2.0"}}
+	invocation := g.startInvocation(plugin, []string{"--password", "hunter2", "API_KEY=abc", "prod"})
+	g.finishInvocation(invocation, &ExitError{Name: "exampleapp-deploy", Code: 2})
+
+	invocations, err := g.Invocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invocations) != 1 {
+		t.Fatalf("Expected expired invocation to be pruned, got %+v", invocations)
+	}
+
+	actual := invocations[0]
+	expectedArgs := []string{"--password", redacted, "API_KEY=" + redacted, "prod"}
+
+	if actual.Plugin != "deploy" || actual.Version != "1.2.0" || !reflect.DeepEqual(actual.Args, expectedArgs) {
+		t.Errorf("Unexpected invocation recorded %+v", actual)
+	}
+
+	if actual.ExitCode == nil || *actual.ExitCode != 2 {
+		t.Errorf("Expected exit code 2, got %v", actual.ExitCode)
+	}
+
+	g.AuditRetention = -1
+	if invocation := g.startInvocation(plugin, nil); invocation != nil {
+		t.Errorf("Expected audit log to be turned off, got %+v", invocation)
+	}
+}
@@ -0,0 +1,87 @@
+package plugged
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// GatewayEnv is the environment variable plugins find the gateway running
+// them in.
+const GatewayEnv = "PLUGGED_GATEWAY"
+
+// ExitError is returned when a plugin, run as a child process or through
+// GatewayClientT.Call, exits with non-zero status Code.
+type ExitError struct {
+	Name string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("Plugin '%s' exited with status %d", e.Name, e.Code)
+}
+
+// GatewayClientT lets plugin run other commands of the gateway running it,
+// resolved the same way as when user runs them: with aliases, workflows and
+// project manifest requirements.
+type GatewayClientT struct {
+	// Path is the gateway executable.
+	Path string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// CurrentGateway returns client of the gateway running the plugin, with
+// standard streams of the plugin.
+func CurrentGateway() (*GatewayClientT, error) {
+	path := os.Getenv(GatewayEnv)
+	if path == "" {
+		return nil, fmt.Errorf("Plugin is not run by a gateway, %s is not set", GatewayEnv)
+	}
+
+	return &GatewayClientT{
+		Path:   path,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}, nil
+}
+
+// Call runs the named command with args through the gateway and waits for
+// it to finish, eg. gateway.Call("build", []string{"--prod"}). When the
+// command fails, or the gateway can not resolve or run it, the error is
+// *ExitError with its exit status.
+func (c *GatewayClientT) Call(name string, args []string) error {
+	cmd := exec.Command(c.Path, append([]string{name}, args...)...)
+	cmd.Stdin = c.Stdin
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return &ExitError{Name: name, Code: exitErr.ExitCode()}
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to call '%s' through the gateway - %s", name, err)
+	}
+
+	return nil
+}
+
+// executable is what plugins get as GatewayEnv.
+func (g *GatewayT) executable() string {
+	if g.Executable != "" {
+		return g.Executable
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
@@ -0,0 +1,110 @@
+package plugged
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGatewayClientCall(t *testing.T) {
+	if err := os.MkdirAll("./tmp/call", 0777); err != nil {
+		t.Fatalf("Unable to create directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/call")
+
+	os.Unsetenv(GatewayEnv)
+	if _, err := CurrentGateway(); err == nil {
+		t.Errorf("Expected an error without %s", GatewayEnv)
+	}
+
+	gateway := "#!/usr/bin/env sh\necho \"$@\"\ntest \"$1\" = fail && exit 3\nexit 0\n"
+	if err := ioutil.WriteFile("./tmp/call/exampleapp", []byte(gateway), 0777); err != nil {
+		t.Fatalf("Unable to create gateway - %s", err)
+	}
+
+	os.Setenv(GatewayEnv, "./tmp/call/exampleapp")
+	defer os.Unsetenv(GatewayEnv)
+
+	client, err := CurrentGateway()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := &bytes.Buffer{}
+	client.Stdout = stdout
+
+	if err := client.Call("build", []string{"--prod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Call("fail", nil)
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 3 {
+		t.Errorf("Expected exit status 3, got %v", err)
+	}
+
+	if expected := "build --prod\nfail\n"; stdout.String() != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, stdout.String())
+	}
+}
+
+// TestHelperGateway is the gateway executable of TestGatewayClientCallUnknown,
+// it does nothing in a regular test run.
+func TestHelperGateway(t *testing.T) {
+	if os.Getenv("PLUGGED_HELPER_GATEWAY") == "" {
+		return
+	}
+
+	args := []string{"exampleapp"}
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = append(args, os.Args[i+1:]...)
+			break
+		}
+	}
+
+	Gateway("exampleapp", "An example CLI application.", args)
+	os.Exit(0)
+}
+
+func TestGatewayClientCallUnknown(t *testing.T) {
+	if err := os.MkdirAll("./tmp/call-unknown", 0777); err != nil {
+		t.Fatalf("Unable to create directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/call-unknown")
+
+	test, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home, _ := filepath.Abs("./tmp/call-unknown")
+	gateway := fmt.Sprintf(
+		"#!/usr/bin/env sh\nHOME=%s PLUGGED_HELPER_GATEWAY=1 exec %s -test.run='^TestHelperGateway$' -- \"$@\"\n",
+		home,
+		test,
+	)
+	if err := ioutil.WriteFile("./tmp/call-unknown/exampleapp", []byte(gateway), 0777); err != nil {
+		t.Fatalf("Unable to create gateway - %s", err)
+	}
+
+	stderr := &bytes.Buffer{}
+	client := &GatewayClientT{Path: "./tmp/call-unknown/exampleapp", Stdout: &bytes.Buffer{}, Stderr: stderr}
+
+	err = client.Call("missing", nil)
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Name != "missing" || exitErr.Code != 1 {
+		t.Errorf("Expected unknown command to exit with status 1, got %v", err)
+	}
+
+	err = client.Call("--plugged-alias", nil)
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 1 {
+		t.Errorf("Expected wrong arguments to exit with status 1, got %v", err)
+	}
+
+	// Errors shown in the output already are not reported once more.
+	if stderr.Len() != 0 {
+		t.Errorf("Expected nothing on stderr, got %q", stderr.String())
+	}
+}
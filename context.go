@@ -0,0 +1,240 @@
+package plugged
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// defaultProbeTimeout bounds how long plugins can take to answer
+// --plugged-description, --plugged-metadata and --help, unless
+// GatewayT.ProbeTimeout says otherwise.
+const defaultProbeTimeout = 10 * time.Second
+
+// probeWaitDelay bounds how long output of a killed probe is still read,
+// when processes plugin started keep it open.
+const probeWaitDelay = 100 * time.Millisecond
+
+// RunContext is Run, which stops plugin probes and child processes in
+// progress and does not start new ones, once ctx is done.
+func (g *GatewayT) RunContext(ctx context.Context, args []string) error {
+	previous := g.ctx
+	g.ctx = ctx
+	defer func() { g.ctx = previous }()
+
+	return g.Run(args)
+}
+
+// ConnectContext is Connect, which gives up waiting for the database lock
+// once ctx is done.
+func (g *GatewayT) ConnectContext(ctx context.Context) error {
+	g.connectErr = g.connect(ctx)
+	return g.connectErr
+}
+
+func (g *GatewayT) connect(ctx context.Context) error {
+	if g.Store == nil {
+		store, err := openBoltStore(ctx, g.databasePath())
+		if err != nil {
+			return fmt.Errorf("Unable to connect to embedded database - %s", err)
+		}
+
+		g.Store = &indexedStore{Store: store, indexPath: g.indexPath()}
+		g.indexed = true
+	}
+
+	if err := migrate(g.Store); err != nil {
+		return fmt.Errorf("Unable to migrate plugin registry - %s", err)
+	}
+
+	if g.indexed {
+		return g.unspoolInvocations()
+	}
+
+	return nil
+}
+
+// openBoltStore opens bolt store in background, so that waiting for the
+// lock can be abandoned. The store is closed as soon as it opens then.
+func openBoltStore(ctx context.Context, path string) (Store, error) {
+	type resultT struct {
+		store Store
+		err   error
+	}
+
+	opened := make(chan resultT, 1)
+	go func() {
+		store, err := NewBoltStore(path)
+		opened <- resultT{store, err}
+	}()
+
+	select {
+	case result := <-opened:
+		return result.store, result.err
+	case <-ctx.Done():
+		go func() {
+			if result := <-opened; result.err == nil {
+				result.store.Close()
+			}
+		}()
+
+		return nil, fmt.Errorf("Gave up waiting for %s to be unlocked by another process - %s", path, ctx.Err())
+	}
+}
+
+func (g *GatewayT) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+
+	return g.ctx
+}
+
+// interruptsT dispatches SIGINT to the command in flight: the most recently
+// armed context is cancelled, so that eg. the shell survives interrupting
+// the command it runs. Interrupting the same command again terminates the
+// process as usual.
+type interruptsT struct {
+	mu    sync.Mutex
+	armed []*armedT
+}
+
+type armedT struct {
+	cancel      context.CancelFunc
+	interrupted bool
+}
+
+// newInterrupts starts handling SIGINT of the process.
+func newInterrupts() *interruptsT {
+	i := &interruptsT{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		for range signals {
+			if !i.interrupt() {
+				os.Exit(130)
+			}
+		}
+	}()
+
+	return i
+}
+
+// arm makes the next SIGINT call cancel, until stop is called. Nil cancel
+// makes SIGINT do nothing instead.
+func (i *interruptsT) arm(cancel context.CancelFunc) (stop func()) {
+	armed := &armedT{cancel: cancel}
+
+	i.mu.Lock()
+	i.armed = append(i.armed, armed)
+	i.mu.Unlock()
+
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		for n, other := range i.armed {
+			if other == armed {
+				i.armed = append(i.armed[:n], i.armed[n+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// interrupt cancels the most recently armed context, and reports false
+// when the process should terminate instead.
+func (i *interruptsT) interrupt() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.armed) == 0 {
+		return false
+	}
+
+	armed := i.armed[len(i.armed)-1]
+	if armed.cancel == nil {
+		return true
+	}
+
+	if armed.interrupted {
+		return false
+	}
+
+	armed.interrupted = true
+	armed.cancel()
+	return true
+}
+
+// interruptible returns context of a command, which SIGINT cancels when
+// the gateway handles signals, and stop to call once the command is done.
+func (g *GatewayT) interruptible() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(g.context())
+	if g.interrupts == nil {
+		return ctx, cancel
+	}
+
+	disarm := g.interrupts.arm(cancel)
+	return ctx, func() {
+		disarm()
+		cancel()
+	}
+}
+
+// ignoreInterrupts makes SIGINT do nothing, until resume is called.
+func (g *GatewayT) ignoreInterrupts() (resume func()) {
+	if g.interrupts == nil {
+		return func() {}
+	}
+
+	return g.interrupts.arm(nil)
+}
+
+func (g *GatewayT) probeTimeout() time.Duration {
+	if g.ProbeTimeout <= 0 {
+		return defaultProbeTimeout
+	}
+
+	return g.ProbeTimeout
+}
+
+// probe runs plugin binary with args and returns its output, killing it,
+// and processes it started, when it takes longer than the probe timeout.
+func (g *GatewayT) probe(p *pluginT, binary string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(g.context(), g.probeTimeout())
+	defer cancel()
+
+	var output []byte
+	var err error
+
+	if isWasm(binary) {
+		var launch *launchT
+		if launch, err = g.launch(p, binary, args); err == nil {
+			buf := &bytes.Buffer{}
+			err = runWasm(ctx, launch.Binary, launch.Argv, launch.Env, nil, buf, nil, nil)
+			output = buf.Bytes()
+		}
+	} else {
+		var cmd *exec.Cmd
+		if cmd, err = g.pluginCommand(ctx, p, binary, args...); err == nil {
+			killProcessGroup(cmd)
+			cmd.WaitDelay = probeWaitDelay
+			output, err = cmd.Output()
+		}
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("timed out after %s", g.probeTimeout())
+	}
+	if ctx.Err() != nil {
+		return output, ctx.Err()
+	}
+
+	return output, err
+}
@@ -0,0 +1,131 @@
+package plugged
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeTimeout(t *testing.T) {
+	if err := os.MkdirAll("./tmp/context", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/context")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/context:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	plugin := "#!/usr/bin/env sh\nexec sleep 5\n"
+	if err := ioutil.WriteFile("./tmp/context/exampleapp-hang", []byte(plugin), 0777); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	// Without exec, sleep is a child of the plugin holding its output open.
+	plugin = "#!/usr/bin/env sh\nsleep 5\n"
+	if err := ioutil.WriteFile("./tmp/context/exampleapp-nap", []byte(plugin), 0777); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	g := &GatewayT{
+		Stdout:       stdout,
+		Name:         "exampleapp",
+		Store:        NewMemoryStore(),
+		ProbeTimeout: 100 * time.Millisecond,
+	}
+
+	started := time.Now()
+	if err := g.Run([]string{"exampleapp", "--plugged-install", "hang", "nap"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Expected probe to be killed on timeout, took %s", elapsed)
+	}
+
+	expected := "hang: Failed to get metadata - 'exampleapp-hang --plugged-description' returned an error - timed out after 100ms\n" +
+		"nap: Failed to get metadata - 'exampleapp-nap --plugged-description' returned an error - timed out after 100ms\n"
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := g.RunContext(ctx, []string{"exampleapp", "--plugged-install", "hang"})
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("Expected cancelled run, got %v", err)
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	g := &GatewayT{interrupts: &interruptsT{}}
+
+	gateway, stopGateway := g.interruptible()
+	defer stopGateway()
+	g.ctx = gateway
+
+	line, stopLine := g.interruptible()
+	if !g.interrupts.interrupt() {
+		t.Fatal("Expected the first interrupt to be handled")
+	}
+	if line.Err() == nil || gateway.Err() != nil {
+		t.Errorf("Expected only the command in flight to be cancelled, got %v and %v", line.Err(), gateway.Err())
+	}
+	if g.interrupts.interrupt() {
+		t.Error("Expected the second interrupt of the same command to terminate the process")
+	}
+	stopLine()
+
+	resume := g.ignoreInterrupts()
+	if !g.interrupts.interrupt() || !g.interrupts.interrupt() || gateway.Err() != nil {
+		t.Errorf("Expected interrupts to be ignored, got %v", gateway.Err())
+	}
+	resume()
+
+	line, stopLine = g.interruptible()
+	defer stopLine()
+	if line.Err() != nil {
+		t.Errorf("Expected the next command to run, got %v", line.Err())
+	}
+}
+
+func TestDoctorReportsLockedStore(t *testing.T) {
+	if err := os.MkdirAll("./tmp/locked", 0777); err != nil {
+		t.Fatalf("Unable to create home directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/locked")
+
+	stdout := &bytes.Buffer{}
+	g := &GatewayT{Stdout: stdout, Home: "./tmp/locked", Name: "exampleapp"}
+
+	other, err := NewBoltStore(g.databasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := g.ConnectContext(ctx); err == nil {
+		t.Fatal("Expected locked store not to be opened")
+	}
+	defer g.Disconnect()
+
+	err = g.Run([]string{"exampleapp", "--plugged-doctor"})
+	if err == nil || err.Error() != "Found 1 problem(s) with exampleapp installation" {
+		t.Errorf("Expected the lock to be reported as a problem, got %v", err)
+	}
+
+	expected := "store: problem - Unable to connect to embedded database - " +
+		"Gave up waiting for ./tmp/locked/.exampleapp.db to be unlocked by another process - context deadline exceeded\n"
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+}
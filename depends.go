@@ -0,0 +1,218 @@
+package plugged
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dependencyError is why plugin can not be installed together with its
+// dependencies, as opposed to failing to get its metadata.
+type dependencyError struct {
+	message string
+}
+
+func (e *dependencyError) Error() string {
+	return e.message
+}
+
+func dependencyErrorf(format string, args ...interface{}) *dependencyError {
+	return &dependencyError{message: fmt.Sprintf(format, args...)}
+}
+
+// dependencies returns names of plugins plugin depends on, sorted.
+func (p *pluginT) dependencies() []string {
+	names := []string{}
+	for name := range p.Depends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// checkDependencies fails installs that would leave dependencies unmet: of
+// plugins being installed, that have a dependency cycle, a dependency that
+// can not be installed or has a version not satisfying their constraint,
+// and of installed plugins, that depend on a plugin being installed with a
+// version not satisfying their constraint. Failures propagate to
+// dependents, until there is nothing more to fail.
+func checkDependencies(installs []*installT, installed map[string]*pluginT) {
+	byName := map[string]*installT{}
+	for _, install := range installs {
+		byName[install.Name] = install
+	}
+
+	// lookup returns plugin as it will be after the installation.
+	lookup := func(name string) *pluginT {
+		if install, ok := byName[name]; ok && install.Err == nil {
+			return install.Plugin
+		}
+
+		return installed[name]
+	}
+
+	// Cycles are found before failing any of the plugins, as a failed
+	// plugin is not a part of the cycle anymore.
+	cycles := map[*installT][]string{}
+	for _, install := range installs {
+		if install.Err == nil {
+			cycles[install] = dependencyCycle(install.Name, lookup)
+		}
+	}
+
+	for install, cycle := range cycles {
+		if cycle != nil {
+			install.Err = dependencyErrorf("Dependency cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, install := range installs {
+			if install.Err != nil {
+				continue
+			}
+
+			if err := unmetDependency(install, byName, installed, lookup); err != nil {
+				install.Err = err
+				changed = true
+			}
+		}
+	}
+
+	// Dependencies are only installed for plugins that are installed.
+	needed := map[string]bool{}
+	for _, install := range installs {
+		if install.Err == nil && install.Dependent == "" {
+			markNeeded(install.Name, lookup, needed)
+		}
+	}
+
+	for _, install := range installs {
+		if install.Err == nil && !needed[install.Name] {
+			install.Err = dependencyErrorf("Not needed, as '%s' failed to install", install.Dependent)
+		}
+
+		if install.Err != nil {
+			install.Plugin = nil
+		}
+	}
+}
+
+func markNeeded(name string, lookup func(string) *pluginT, needed map[string]bool) {
+	p := lookup(name)
+	if p == nil || needed[name] {
+		return
+	}
+
+	needed[name] = true
+	for _, dep := range p.dependencies() {
+		markNeeded(dep, lookup, needed)
+	}
+}
+
+func unmetDependency(install *installT, byName map[string]*installT, installed map[string]*pluginT, lookup func(string) *pluginT) error {
+	p := install.Plugin
+
+	for _, name := range p.dependencies() {
+		constraint := p.Depends[name]
+
+		dep := lookup(name)
+		if dep != nil {
+			if ok, err := versionSatisfies(dep.Version, constraint); err == nil && ok {
+				continue
+			}
+		}
+
+		// Installed version is used when dependency fails to install, so
+		// its error only matters when that one does not do either.
+		if failed, ok := byName[name]; ok && failed.Err != nil {
+			return dependencyErrorf("Unable to install dependency '%s' - %s", name, failed.Err)
+		}
+
+		if dep == nil {
+			return dependencyErrorf("Dependency '%s' is not installed", name)
+		}
+
+		return dependencyErrorf("Dependency '%s' version '%s' does not satisfy '%s'", name, dep.Version, constraint)
+	}
+
+	names := []string{}
+	for name := range installed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		dependent := lookup(name)
+		if dependent == nil {
+			continue
+		}
+
+		constraint, ok := dependent.Depends[p.Name]
+		if !ok {
+			continue
+		}
+
+		if ok, err := versionSatisfies(p.Version, constraint); err != nil || !ok {
+			return dependencyErrorf("Version '%s' does not satisfy '%s' required by '%s'", p.Version, constraint, name)
+		}
+	}
+
+	return nil
+}
+
+// dependencyCycle returns a cycle reachable from plugin through its
+// dependencies, eg. [a b a], or nil when there is none.
+func dependencyCycle(name string, lookup func(string) *pluginT) []string {
+	path := []string{}
+	done := map[string]bool{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		for i, visited := range path {
+			if visited == name {
+				return append(append([]string{}, path[i:]...), name)
+			}
+		}
+
+		p := lookup(name)
+		if p == nil || done[name] {
+			return nil
+		}
+
+		path = append(path, name)
+		for _, dep := range p.dependencies() {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[name] = true
+		return nil
+	}
+
+	return visit(name)
+}
+
+// dependents returns installed plugins depending on the named one, sorted.
+func dependents(plugins Bucket, name string) ([]string, error) {
+	found := []string{}
+
+	list, _, err := listPlugins(plugins)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range list {
+		if _, ok := p.Depends[name]; ok {
+			found = append(found, p.Name)
+		}
+	}
+
+	sort.Strings(found)
+	return found, nil
+}
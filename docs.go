@@ -0,0 +1,168 @@
+package plugged
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// docsFormatT describes how to render reference pages in one of the formats
+// supported by --plugged-docs.
+type docsFormatT struct {
+	Extension       string
+	GatewayTemplate string
+	PluginTemplate  string
+}
+
+var docsFormats = map[string]*docsFormatT{
+	"markdown": {
+		Extension:       ".md",
+		GatewayTemplate: "markdownGateway",
+		PluginTemplate:  "markdownPlugin",
+	},
+	"man": {
+		Extension:       ".1",
+		GatewayTemplate: "manGateway",
+		PluginTemplate:  "manPlugin",
+	},
+}
+
+// docsResultT is JSON output of --plugged-docs.
+type docsResultT struct {
+	Files []string `json:"Files"`
+}
+
+func (g *GatewayT) docsAction(_ string, args []string) error {
+	usage := "--plugged-docs [--format man|markdown] [--out dir] [--all]"
+	format := "markdown"
+	out := "."
+	all := false
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--format" && i+1 < len(args):
+			i++
+			format = args[i]
+		case args[i] == "--out" && i+1 < len(args):
+			i++
+			out = args[i]
+		case args[i] == "--all":
+			all = true
+		default:
+			return g.showUsage(usage)
+		}
+	}
+
+	docsFormat, ok := docsFormats[format]
+	if !ok {
+		return g.showUsage(usage)
+	}
+
+	plugins, err := g.Plugins()
+	if err != nil {
+		return err
+	}
+
+	pages := []*pluginPageView{}
+	commands := []*commandT{}
+
+	for _, p := range plugins {
+		if p.Hidden && !all {
+			continue
+		}
+
+		pages = append(pages, &pluginPageView{
+			AppName: g.Name,
+			Command: p.command(),
+			Plugin:  p,
+			Help:    p.captureHelp(g),
+		})
+
+		commands = append(commands, &commandT{
+			Name:        p.Name,
+			Description: p.Description,
+			Category:    p.Category,
+		})
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return fmt.Errorf("Unable to create docs directory %s - %s", out, err)
+	}
+
+	result := &docsResultT{Files: []string{}}
+
+	gatewayPage := &gatewayPageView{
+		Name:        g.Name,
+		Description: g.Description,
+		Groups:      groupCommands(commands),
+	}
+
+	path := filepath.Join(out, g.Name+docsFormat.Extension)
+	if err := g.writePage(path, docsFormat.GatewayTemplate, gatewayPage); err != nil {
+		return err
+	}
+	result.Files = append(result.Files, path)
+
+	for _, page := range pages {
+		path := filepath.Join(out, page.Command+docsFormat.Extension)
+		if err := g.writePage(path, docsFormat.PluginTemplate, page); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, path)
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(result)
+	}
+
+	for _, path := range result.Files {
+		fmt.Fprintf(g.Stdout, "Wrote %s\n", path)
+	}
+
+	return nil
+}
+
+func (g *GatewayT) writePage(path, templateName string, page interface{}) error {
+	buf := &bytes.Buffer{}
+
+	if err := g.template(templateName).Execute(buf, page); err != nil {
+		return fmt.Errorf("Unable to execute %s template on %v - %s", templateName, page, err)
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Unable to write %s - %s", path, err)
+	}
+
+	return nil
+}
+
+// captureHelp returns output of plugin's --help, or empty string if it
+// could not be obtained.
+func (p *pluginT) captureHelp(g *GatewayT) string {
+	binary, err := g.findBinary(p.command())
+	if err != nil {
+		return ""
+	}
+
+	// Plugins often exit with non-zero status after printing help, so the
+	// output is used regardless.
+	help, _ := g.probe(p, binary, "--help")
+	return strings.TrimRight(string(help), "\n")
+}
+
+// manEscape escapes text, so that it is shown as is in a man page.
+func manEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
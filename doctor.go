@@ -0,0 +1,352 @@
+package plugged
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// checkT is a single result of --plugged-doctor.
+type checkT struct {
+	Subject string `json:"Subject"`
+	Status  string `json:"Status"`
+	Details string `json:"Details,omitempty"`
+}
+
+const (
+	checkOK       = "ok"
+	checkWarning  = "warning"
+	checkProblem  = "problem"
+	checkRepaired = "repaired"
+	checkRemoved  = "removed"
+)
+
+// doctorReportT is what --plugged-doctor outputs in JSON mode.
+type doctorReportT struct {
+	Checks   []*checkT `json:"Checks"`
+	Problems int       `json:"Problems"`
+}
+
+func (g *GatewayT) doctorAction(_ string, args []string) error {
+	repair := false
+	for _, arg := range args {
+		if arg != "--repair" {
+			return g.showUsage("--plugged-doctor [--repair]")
+		}
+		repair = true
+	}
+
+	checks := []*checkT{g.checkStore()}
+
+	// Nothing else can be checked without the registry.
+	if g.Store != nil {
+		registry, err := g.checkRegistry(repair)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, registry...)
+	}
+
+	report := &doctorReportT{Checks: checks}
+	for _, check := range checks {
+		if check.Status == checkProblem {
+			report.Problems++
+		}
+	}
+
+	if g.output == outputJSON {
+		if err := g.renderJSON(report); err != nil {
+			return err
+		}
+	} else {
+		view := &doctorReportView{Checks: checks}
+		if err := view.render(g.template("doctorReport"), g.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if report.Problems > 0 {
+		return renderedError{fmt.Errorf("Found %d problem(s) with %s installation", report.Problems, g.Name)}
+	}
+
+	return nil
+}
+
+// checkStore verifies that the registry could be opened, eg. that its
+// database is not locked by another process, and that it can be read.
+func (g *GatewayT) checkStore() *checkT {
+	check := &checkT{Subject: "store", Status: checkOK}
+
+	switch {
+	case g.connectErr != nil:
+		check.Status = checkProblem
+		check.Details = g.connectErr.Error()
+	case g.Store == nil:
+		check.Status = checkProblem
+		check.Details = "not connected"
+	default:
+		if err := g.Store.View(func(tx Tx) error { return nil }); err != nil {
+			check.Status = checkProblem
+			check.Details = err.Error()
+		}
+	}
+
+	return check
+}
+
+func (g *GatewayT) checkRegistry(repair bool) ([]*checkT, error) {
+	schema, err := g.checkSchema()
+	if err != nil {
+		return nil, err
+	}
+	checks := []*checkT{schema}
+
+	corrupted, err := g.checkCorrupted(repair)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, corrupted...)
+
+	plugins, err := g.checkPlugins()
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, plugins...)
+
+	aliases, err := g.checkAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(checks, aliases...), nil
+}
+
+func (g *GatewayT) checkSchema() (*checkT, error) {
+	check := &checkT{Subject: "registry"}
+
+	err := g.Store.View(func(tx Tx) error {
+		version, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		check.Status = checkOK
+		check.Details = fmt.Sprintf("schema version %d", version)
+
+		if version != len(migrations) {
+			check.Status = checkProblem
+			check.Details += fmt.Sprintf(", expected %d", len(migrations))
+		}
+
+		return nil
+	})
+
+	return check, err
+}
+
+// checkCorrupted finds registry entries that can not be decoded. When repair
+// is requested, they are reinstalled from PATH or removed if that fails.
+func (g *GatewayT) checkCorrupted(repair bool) ([]*checkT, error) {
+	checks := []*checkT{}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("plugins")
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(key, data []byte) error {
+			if _, err := decodePlugin(data, string(key)); err != nil {
+				checks = append(checks, &checkT{
+					Subject: string(key),
+					Status:  checkProblem,
+					Details: "corrupted registry entry",
+				})
+			}
+			return nil
+		})
+	})
+
+	if err != nil || !repair {
+		return checks, err
+	}
+
+	names := []string{}
+	for _, check := range checks {
+		names = append(names, check.Subject)
+	}
+
+	installs, err := g.installPlugins(names)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, check := range checks {
+		if installs[i].Err == nil {
+			check.Status = checkRepaired
+			check.Details = "reinstalled from " + installs[i].Plugin.Path
+			continue
+		}
+
+		if err := g.removePlugin(check.Subject); err != nil {
+			return nil, err
+		}
+
+		check.Status = checkRemoved
+		check.Details = "unable to reinstall, entry was removed"
+	}
+
+	return checks, nil
+}
+
+func (g *GatewayT) checkPlugins() ([]*checkT, error) {
+	plugins, err := g.Plugins()
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []*checkT{}
+	for _, p := range plugins {
+		checks = append(checks, p.diagnose(g)...)
+	}
+
+	return checks, nil
+}
+
+// diagnose verifies that plugin binary can still be found and that it
+// still talks plugged protocol.
+func (p *pluginT) diagnose(g *GatewayT) []*checkT {
+	cmdName := p.command()
+
+	binary, err := g.findBinary(cmdName)
+	if err != nil {
+		return []*checkT{{
+			Subject: p.Name,
+			Status:  checkProblem,
+			Details: fmt.Sprintf("'%s' can not be found on PATH", cmdName),
+		}}
+	}
+
+	checks := []*checkT{}
+
+	if info, err := os.Stat(binary); err != nil || info.Mode()&0111 == 0 && !isWasm(binary) && g.interpreter(binary) == nil {
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkProblem,
+			Details: fmt.Sprintf("%s is not executable", binary),
+		})
+	}
+
+	if _, err := g.probe(p, binary, "--plugged-description"); err != nil {
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkProblem,
+			Details: fmt.Sprintf("'%s --plugged-description' returned an error - %s", cmdName, err),
+		})
+	}
+
+	if p.Path != "" && p.Path != binary {
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkWarning,
+			Details: fmt.Sprintf("resolves to %s, but %s was installed", binary, p.Path),
+		})
+	} else if checksum, err := fileChecksum(binary); err == nil && p.Checksum != "" && checksum != p.Checksum {
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkWarning,
+			Details: fmt.Sprintf("%s has changed since installation", binary),
+		})
+	}
+
+	// Binary resolves from PluginDirs or to a script as well, so every other
+	// executable on PATH is shadowed by it.
+	for _, shadowed := range lookPathAll(cmdName) {
+		if shadowed == binary {
+			continue
+		}
+
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkWarning,
+			Details: fmt.Sprintf("%s is shadowed by %s", shadowed, binary),
+		})
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, &checkT{
+			Subject: p.Name,
+			Status:  checkOK,
+			Details: binary,
+		})
+	}
+
+	return checks
+}
+
+func (g *GatewayT) checkAliases() ([]*checkT, error) {
+	aliases, err := g.Aliases()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+
+	checks := []*checkT{}
+
+	err = g.Store.View(func(tx Tx) error {
+		plugins := tx.Bucket("plugins")
+
+		for _, alias := range names {
+			if plugins != nil && plugins.Get([]byte(alias)) != nil {
+				checks = append(checks, &checkT{
+					Subject: alias,
+					Status:  checkWarning,
+					Details: fmt.Sprintf("alias for '%s' hides installed plugin with the same name", aliases[alias]),
+				})
+			}
+
+			if plugins == nil || plugins.Get([]byte(aliases[alias])) == nil {
+				checks = append(checks, &checkT{
+					Subject: alias,
+					Status:  checkProblem,
+					Details: fmt.Sprintf("alias for '%s', which is not installed", aliases[alias]),
+				})
+			}
+		}
+
+		return nil
+	})
+
+	return checks, err
+}
+
+// lookPathAll finds all executables named file in PATH, in order of
+// precedence.
+func lookPathAll(file string) []string {
+	found := []string{}
+	seen := map[string]bool{}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+
+		path := filepath.Join(dir, file)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			found = append(found, path)
+		}
+	}
+
+	return found
+}
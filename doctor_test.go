@@ -0,0 +1,45 @@
+package plugged
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDiagnoseShadowedByPluginDirs(t *testing.T) {
+	for _, dir := range []string{"./tmp/doctor/bin", "./tmp/doctor/plugins"} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatalf("Unable to create directory %s - %s", dir, err)
+		}
+	}
+	defer os.RemoveAll("./tmp/doctor")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/doctor/bin:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	plugin := "#!/usr/bin/env sh\necho -n Find some stuff.\n"
+	for _, path := range []string{"./tmp/doctor/bin/exampleapp-find", "./tmp/doctor/plugins/exampleapp-find"} {
+		if err := ioutil.WriteFile(path, []byte(plugin), 0777); err != nil {
+			t.Fatalf("Unable to create plugin - %s", err)
+		}
+	}
+
+	g := &GatewayT{
+		Stdout:     &bytes.Buffer{},
+		Home:       "./tmp/doctor",
+		Name:       "exampleapp",
+		PluginDirs: []string{"./tmp/doctor/plugins"},
+	}
+
+	p := newPlugin("exampleapp", "find")
+	p.Path = "tmp/doctor/plugins/exampleapp-find"
+
+	checks := p.diagnose(g)
+
+	expected := "tmp/doctor/bin/exampleapp-find is shadowed by tmp/doctor/plugins/exampleapp-find"
+	if len(checks) != 1 || checks[0].Status != checkWarning || checks[0].Details != expected {
+		t.Errorf("Expected plugin on PATH to be reported as shadowed, got %+v", checks)
+	}
+}
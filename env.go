@@ -0,0 +1,268 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// globalEnvRules is the key of rules applying to all plugins in bucket
+// 'env', other keys are plugin names.
+const globalEnvRules = "*"
+
+// envRulesT decide which environment variables are passed to plugins.
+// Patterns are matched against variable names with path.Match, eg. "AWS_*".
+type envRulesT struct {
+	Allow []string `json:"Allow,omitempty"`
+	Deny  []string `json:"Deny,omitempty"`
+}
+
+// envReportT is what --plugged-env outputs in JSON mode for a plugin.
+type envReportT struct {
+	Plugin string   `json:"Plugin"`
+	Env    []string `json:"Env"`
+}
+
+// decide reports whether variable is allowed, and whether any rule matched
+// it at all. Deny rules win over allow rules.
+func (r *envRulesT) decide(name string) (bool, bool) {
+	if r == nil {
+		return false, false
+	}
+
+	if matchesAny(r.Deny, name) {
+		return false, true
+	}
+
+	if matchesAny(r.Allow, name) {
+		return true, true
+	}
+
+	return false, false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// envAllowed decides on variable by plugin rules first, then by global
+// ones. Variables no rule matches are allowed, unless there is an allowlist.
+func envAllowed(name string, plugin, global *envRulesT) bool {
+	if allowed, matched := plugin.decide(name); matched {
+		return allowed
+	}
+
+	if allowed, matched := global.decide(name); matched {
+		return allowed
+	}
+
+	return (plugin == nil || len(plugin.Allow) == 0) && (global == nil || len(global.Allow) == 0)
+}
+
+// EnvRules returns environment rules by plugin name, the ones applying to
+// all plugins are under "*".
+func (g *GatewayT) EnvRules() (map[string]*envRulesT, error) {
+	// Plugins dispatched via the index do not open the registry.
+	if g.Store == nil {
+		if index := g.readIndex(); index != nil {
+			return index.Env, nil
+		}
+		return nil, nil
+	}
+
+	rules := map[string]*envRulesT{}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("env")
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(name, data []byte) error {
+			r := &envRulesT{}
+			if err := json.Unmarshal(data, r); err != nil {
+				return fmt.Errorf("Unable to unmarshal env rules of '%s' - %s", name, err)
+			}
+
+			rules[string(name)] = r
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get env rules - %s", err)
+	}
+
+	return rules, nil
+}
+
+// updateEnvRules updates rules of the named plugin, or of all plugins for
+// "*". Rules are kept under the plugin name, when it is named by an alias.
+func (g *GatewayT) updateEnvRules(name string, update func(r *envRulesT)) error {
+	return g.Store.Update(func(tx Tx) error {
+		if name != globalEnvRules {
+			p, err := resolvePlugin(tx, name)
+			if err != nil {
+				return err
+			}
+			name = p.Name
+		}
+
+		b, err := tx.CreateBucketIfNotExists("env")
+		if err != nil {
+			return fmt.Errorf("Unable to obtain bucket 'env' - %s", err)
+		}
+
+		r := &envRulesT{}
+		if data := b.Get([]byte(name)); data != nil {
+			if err := json.Unmarshal(data, r); err != nil {
+				return fmt.Errorf("Unable to unmarshal env rules of '%s' - %s", name, err)
+			}
+		}
+
+		update(r)
+
+		if len(r.Allow) == 0 && len(r.Deny) == 0 {
+			return b.Delete([]byte(name))
+		}
+
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("Unable to marshal env rules to json - %s", err)
+		}
+
+		if err := b.Put([]byte(name), data); err != nil {
+			return fmt.Errorf("Unable to save env rules to bucket 'env' - %s", err)
+		}
+
+		return nil
+	})
+}
+
+// environ returns environment plugin runs with: scrubbed down to approved
+// variables when sandboxed, and filtered by env rules, followed by
+// GatewayEnv.
+func (g *GatewayT) environ(p *pluginT) ([]string, error) {
+	env := os.Environ()
+	if g.Sandbox {
+		env = p.Approved.environ(env)
+	}
+
+	rules, err := g.EnvRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, entry := range env {
+		name := strings.SplitN(entry, "=", 2)[0]
+		if name != GatewayEnv && envAllowed(name, rules[p.Name], rules[globalEnvRules]) {
+			result = append(result, entry)
+		}
+	}
+
+	if executable := g.executable(); executable != "" {
+		result = append(result, GatewayEnv+"="+executable)
+	}
+
+	return result, nil
+}
+
+func (g *GatewayT) envAction(_ string, args []string) error {
+	name := globalEnvRules
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	switch {
+	case len(args) == 0 && name == globalEnvRules:
+		return g.showEnvRules()
+	case len(args) == 0:
+		return g.showEnv(name)
+	case len(args) == 2 && args[0] == "--allow":
+		return g.updateEnvRules(name, func(r *envRulesT) { r.Allow = append(r.Allow, args[1]) })
+	case len(args) == 2 && args[0] == "--deny":
+		return g.updateEnvRules(name, func(r *envRulesT) { r.Deny = append(r.Deny, args[1]) })
+	case len(args) == 1 && args[0] == "--clear":
+		return g.updateEnvRules(name, func(r *envRulesT) { *r = envRulesT{} })
+	}
+
+	return g.showUsage("--plugged-env [plugin] [--allow pattern | --deny pattern | --clear]")
+}
+
+func (g *GatewayT) showEnvRules() error {
+	rules, err := g.EnvRules()
+	if err != nil {
+		return err
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(rules)
+	}
+
+	names := []string{}
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, pattern := range rules[name].Allow {
+			fmt.Fprintf(g.Stdout, "%s: allow %s\n", name, pattern)
+		}
+
+		for _, pattern := range rules[name].Deny {
+			fmt.Fprintf(g.Stdout, "%s: deny %s\n", name, pattern)
+		}
+	}
+
+	return nil
+}
+
+// showEnv prints environment plugin receives, with sensitive values
+// redacted.
+func (g *GatewayT) showEnv(name string) error {
+	var plugin *pluginT
+	err := g.Store.View(func(tx Tx) error {
+		var err error
+		plugin, err = resolvePlugin(tx, name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	env, err := g.environ(plugin)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(env)
+	for i, entry := range env {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && sensitiveName.MatchString(parts[0]) {
+			env[i] = parts[0] + "=" + redacted
+		} else {
+			env[i] = g.redact(entry)
+		}
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(&envReportT{Plugin: plugin.Name, Env: env})
+	}
+
+	for _, entry := range env {
+		fmt.Fprintln(g.Stdout, entry)
+	}
+
+	return nil
+}
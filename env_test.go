@@ -0,0 +1,114 @@
+package plugged
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestEnvAllowed(t *testing.T) {
+	examples := []struct {
+		name     string
+		plugin   *envRulesT
+		global   *envRulesT
+		expected bool
+	}{
+		{"HOME", nil, nil, true},
+		{"AWS_PROFILE", nil, &envRulesT{Deny: []string{"AWS_*"}}, false},
+		{"AWS_PROFILE", &envRulesT{Allow: []string{"AWS_*"}}, &envRulesT{Deny: []string{"AWS_*"}}, true},
+		{"AWS_SECRET_ACCESS_KEY", &envRulesT{Allow: []string{"AWS_*"}, Deny: []string{"*SECRET*"}}, nil, false},
+		{"HOME", nil, &envRulesT{Allow: []string{"PATH"}}, false},
+		{"PATH", nil, &envRulesT{Allow: []string{"PATH"}}, true},
+	}
+
+	for _, example := range examples {
+		if actual := envAllowed(example.name, example.plugin, example.global); actual != example.expected {
+			t.Errorf("Expected %s allowed to be %v with %+v and %+v", example.name, example.expected, example.plugin, example.global)
+		}
+	}
+}
+
+func TestEnvAction(t *testing.T) {
+	for name, value := range map[string]string{
+		"PLUGGED_TEST_NAME":  "deploy",
+		"PLUGGED_TEST_TOKEN": "hunter2",
+		"AWS_PLUGGED_TEST":   "profile",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	stdout := &bytes.Buffer{}
+	g := &GatewayT{
+		Stdout:     stdout,
+		Name:       "exampleapp",
+		Store:      NewMemoryStore(),
+		Executable: "/usr/bin/exampleapp",
+	}
+
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("plugins")
+		if err != nil {
+			return err
+		}
+
+		if err := newPlugin("exampleapp", "deploy").save(b); err != nil {
+			return err
+		}
+		if err := newPlugin("exampleapp", "find").save(b); err != nil {
+			return err
+		}
+
+		aliases, err := tx.CreateBucketIfNotExists("aliases")
+		if err != nil {
+			return err
+		}
+		return aliases.Put([]byte("d"), []byte("deploy"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"exampleapp", "--plugged-env", "--allow", "*PLUGGED_TEST*"},
+		{"exampleapp", "--plugged-env", "--deny", "AWS_*"},
+		{"exampleapp", "--plugged-env", "deploy", "--allow", "AWS_PLUGGED_*"},
+		{"exampleapp", "--plugged-env"},
+		{"exampleapp", "--plugged-env", "deploy"},
+		{"exampleapp", "--plugged-env", "find"},
+		{"exampleapp", "--plugged-env", "deploy", "--clear"},
+		{"exampleapp", "--plugged-env", "deploy"},
+		{"exampleapp", "--plugged-env", "d", "--deny", "PLUGGED_TEST_TOKEN"},
+		{"exampleapp", "--plugged-env", "deploy"},
+	} {
+		if err := g.Run(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = g.Run([]string{"exampleapp", "--plugged-env", "deplyo", "--deny", "AWS_*"})
+	if err == nil || err.Error() != "Plugin 'deplyo' was not found" {
+		t.Errorf("Expected rules of unknown plugin to be rejected, got %v", err)
+	}
+
+	expected := dedent(`
+          |*: allow *PLUGGED_TEST*
+          |*: deny AWS_*
+          |deploy: allow AWS_PLUGGED_*
+          |AWS_PLUGGED_TEST=profile
+          |PLUGGED_GATEWAY=/usr/bin/exampleapp
+          |PLUGGED_TEST_NAME=deploy
+          |PLUGGED_TEST_TOKEN=[REDACTED]
+          |PLUGGED_GATEWAY=/usr/bin/exampleapp
+          |PLUGGED_TEST_NAME=deploy
+          |PLUGGED_TEST_TOKEN=[REDACTED]
+          |PLUGGED_GATEWAY=/usr/bin/exampleapp
+          |PLUGGED_TEST_NAME=deploy
+          |PLUGGED_TEST_TOKEN=[REDACTED]
+          |PLUGGED_GATEWAY=/usr/bin/exampleapp
+          |PLUGGED_TEST_NAME=deploy
+  `)
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\n=== Expected ===\n%s\n=== Actual ===\n%s", expected, actual)
+	}
+}
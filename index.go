@@ -0,0 +1,338 @@
+package plugged
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// indexT is a snapshot of the registry kept next to the default bolt
+// database, so that plugins can be dispatched without opening it. Every
+// update of the registry removes the index, see indexedStore, and it is
+// also stale once the database file has another modification time or size
+// than when the index was written, eg. after an older version updated it.
+type indexT struct {
+	ModTime int64                 `json:"ModTime"`
+	Size    int64                 `json:"Size"`
+	Plugins map[string]*pluginT   `json:"Plugins"`
+	Aliases map[string]string     `json:"Aliases"`
+	Env     map[string]*envRulesT `json:"Env,omitempty"`
+}
+
+// indexedStore is the default bolt store, which removes the dispatch index
+// before every update. The database file alone does not tell that it was
+// updated, as bolt rewrites pages in place.
+type indexedStore struct {
+	Store
+	indexPath string
+}
+
+func (s *indexedStore) Update(fn func(tx Tx) error) error {
+	if err := os.Remove(s.indexPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove index - %s", err)
+	}
+
+	return s.Store.Update(fn)
+}
+
+func (g *GatewayT) databasePath() string {
+	return g.Home + "/." + g.Name + ".db"
+}
+
+func (g *GatewayT) indexPath() string {
+	return g.Home + "/." + g.Name + ".index"
+}
+
+// maxSpoolSize bounds the spool file, it is rotated once it grows larger.
+// Only one rotated file is kept, so the oldest invocations are lost, when
+// the registry is not opened for long.
+const maxSpoolSize = 1 << 20
+
+// spoolPath is where invocations dispatched via the index are recorded,
+// until they are moved into the audit log on the next Connect.
+func (g *GatewayT) spoolPath() string {
+	return g.Home + "/." + g.Name + ".spool"
+}
+
+func (g *GatewayT) rotatedSpoolPath() string {
+	return g.spoolPath() + ".old"
+}
+
+// RunIndexed runs a plugin using the dispatch index, without connecting to
+// the registry. It reports false when the invocation needs the registry,
+// eg. for built-in commands, or when the index is missing or stale, and then
+// Connect and Run should be used instead.
+func (g *GatewayT) RunIndexed(args []string) (bool, error) {
+	if g.Store != nil || len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		return false, nil
+	}
+
+	name, rest := args[1], args[2:]
+	if _, ok := builtinHandlers[name]; ok {
+		return false, nil
+	}
+
+	index := g.readIndex()
+	if index == nil {
+		return false, nil
+	}
+
+	// Unmet project manifest requirements are warned about by Run.
+	if m, err := g.manifest(); err != nil || m != nil && !index.satisfies(m) {
+		return false, nil
+	}
+
+	if target, ok := index.Aliases[name]; ok {
+		name = target
+	}
+
+	plugin, ok := index.Plugins[name]
+	if !ok {
+		return false, nil
+	}
+
+	// Plugins that can not be found are reported by Run.
+	if _, err := g.findBinary(plugin.command()); err != nil {
+		return false, nil
+	}
+
+	invocation := g.newInvocation(plugin, rest)
+	g.spoolInvocation(invocation)
+
+	err := plugin.run(g, rest)
+	if invocation != nil {
+		invocation.complete(err)
+		g.spoolInvocation(invocation)
+	}
+
+	return true, g.redactError(err)
+}
+
+// satisfies reports whether plugins in the index meet all requirements of
+// manifest, resolving aliases like the registry does.
+func (index *indexT) satisfies(m *manifestT) bool {
+	for name, constraint := range m.Plugins {
+		if target, ok := index.Aliases[name]; ok {
+			name = target
+		}
+
+		p, ok := index.Plugins[name]
+		if !ok {
+			return false
+		}
+
+		if ok, err := versionSatisfies(p.Version, constraint); err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readIndex returns the dispatch index, or nil when it is missing or stale.
+func (g *GatewayT) readIndex() *indexT {
+	info, err := os.Stat(g.databasePath())
+	if err != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(g.indexPath())
+	if err != nil {
+		return nil
+	}
+
+	index := &indexT{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil
+	}
+
+	if index.ModTime != info.ModTime().UnixNano() || index.Size != info.Size() {
+		return nil
+	}
+
+	for name, p := range index.Plugins {
+		p.Name, p.AppName = name, g.Name
+	}
+
+	return index
+}
+
+// writeIndex regenerates the dispatch index from the registry, unless it is
+// still fresh. It is only maintained for the default bolt database opened by
+// Connect.
+func (g *GatewayT) writeIndex() error {
+	if !g.indexed || g.readIndex() != nil {
+		return nil
+	}
+
+	index := &indexT{Plugins: map[string]*pluginT{}}
+
+	// Corrupted entries are reported by Plugins during Run already, and
+	// such plugins are dispatched by Run too.
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("plugins")
+		if b == nil {
+			return nil
+		}
+
+		plugins, _, err := listPlugins(b)
+		for _, p := range plugins {
+			index.Plugins[p.Name] = p
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if index.Aliases, err = g.Aliases(); err != nil {
+		return err
+	}
+
+	if index.Env, err = g.EnvRules(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(g.databasePath())
+	if err != nil {
+		return fmt.Errorf("Unable to stat registry - %s", err)
+	}
+
+	index.ModTime = info.ModTime().UnixNano()
+	index.Size = info.Size()
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal index to json - %s", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(g.indexPath()), filepath.Base(g.indexPath())+".tmp")
+	if err != nil {
+		return fmt.Errorf("Unable to create index - %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Unable to write index - %s", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Unable to write index - %s", err)
+	}
+
+	if err := os.Rename(tmp.Name(), g.indexPath()); err != nil {
+		return fmt.Errorf("Unable to replace index - %s", err)
+	}
+
+	return nil
+}
+
+// spoolInvocation appends invocation to the spool file. Later entries for
+// the same invocation replace earlier ones, when they are moved to the audit
+// log.
+func (g *GatewayT) spoolInvocation(invocation *invocationT) {
+	if invocation == nil {
+		return
+	}
+
+	data, err := json.Marshal(invocation)
+	if err != nil {
+		return
+	}
+
+	if info, err := os.Stat(g.spoolPath()); err == nil && info.Size() > maxSpoolSize {
+		os.Rename(g.spoolPath(), g.rotatedSpoolPath())
+	}
+
+	f, err := os.OpenFile(g.spoolPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		fmt.Fprintf(g.stderr(), "[WARNING] Unable to record invocation of '%s' - %s\n", invocation.Plugin, err)
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(data, '\n'))
+}
+
+// unspoolInvocations moves invocations from the spool files to the audit
+// log, and prunes it. Spool files are renamed first, so that invocations
+// recorded meanwhile go to a new one.
+func (g *GatewayT) unspoolInvocations() error {
+	invocations := []*invocationT{}
+	moved := []string{}
+
+	for _, spool := range []string{g.rotatedSpoolPath(), g.spoolPath()} {
+		path := spool + ".moving"
+		if err := os.Rename(spool, path); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("Unable to move %s - %s", spool, err)
+		}
+		moved = append(moved, path)
+
+		spooled, err := readSpool(path)
+		if err != nil {
+			return err
+		}
+		invocations = append(invocations, spooled...)
+	}
+
+	if len(moved) == 0 {
+		return nil
+	}
+
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("invocations")
+		if err != nil {
+			return err
+		}
+
+		for _, invocation := range invocations {
+			if err := invocation.save(b); err != nil {
+				return err
+			}
+		}
+
+		return pruneInvocations(b, time.Now().UTC().Add(-g.auditRetention()))
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to move invocations to audit log - %s", err)
+	}
+
+	for _, path := range moved {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readSpool(path string) ([]*invocationT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open %s - %s", path, err)
+	}
+	defer f.Close()
+
+	invocations := []*invocationT{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		invocation := &invocationT{}
+
+		// A line could be cut short when the process was killed while
+		// writing it, there is nothing to recover from it.
+		if err := json.Unmarshal(scanner.Bytes(), invocation); err == nil {
+			invocations = append(invocations, invocation)
+		}
+	}
+
+	return invocations, nil
+}
@@ -0,0 +1,175 @@
+package plugged
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunIndexed(t *testing.T) {
+	for _, dir := range []string{"./tmp/index/home", "./tmp/index/bin"} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatalf("Unable to create directory %s - %s", dir, err)
+		}
+	}
+	defer os.RemoveAll("./tmp/index")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/index/bin:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	plugin := "#!/usr/bin/env sh\necho -n Find some stuff.\n"
+	if err := ioutil.WriteFile("./tmp/index/bin/exampleapp-find", []byte(plugin), 0777); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	var executed []string
+	newGateway := func() *GatewayT {
+		return &GatewayT{
+			Stdout:  &bytes.Buffer{},
+			Home:    "./tmp/index/home",
+			Name:    "exampleapp",
+			WorkDir: "./tmp/index",
+			ExecFn: func(_ string, args []string, _ []string) error {
+				executed = args
+				return nil
+			},
+		}
+	}
+
+	session := func(scenario ...[]string) {
+		g := newGateway()
+		if err := g.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer g.Disconnect()
+
+		for _, args := range scenario {
+			if err := g.Run(args); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if ran, _ := newGateway().RunIndexed([]string{"exampleapp", "find"}); ran {
+		t.Errorf("Expected no dispatch without index")
+	}
+
+	session(
+		[]string{"exampleapp", "--plugged-install", "find"},
+		[]string{"exampleapp", "--plugged-alias", "f", "find"},
+	)
+
+	// Project manifest requirements are checked against the index.
+	for manifest, met := range map[string]bool{
+		`{"Plugins": {"f": "*"}}`:         true,
+		`{"Plugins": {"find": ">= 1.0"}}`: false,
+		`{"Plugins": {"missing": ""}}`:    false,
+	} {
+		if err := ioutil.WriteFile("./tmp/index/.exampleapp-plugins.json", []byte(manifest), 0644); err != nil {
+			t.Fatalf("Unable to create manifest - %s", err)
+		}
+
+		if ran, _ := newGateway().RunIndexed([]string{"exampleapp", "find"}); ran != met {
+			t.Errorf("Expected dispatch via index to be %v with manifest %s", met, manifest)
+		}
+	}
+	os.Remove("./tmp/index/.exampleapp-plugins.json")
+	os.Remove(newGateway().spoolPath())
+
+	ran, err := newGateway().RunIndexed([]string{"exampleapp", "f", "--token", "abc"})
+	if !ran || err != nil {
+		t.Fatalf("Expected dispatch via index, got %v, %v", ran, err)
+	}
+
+	expected := []string{"exampleapp-find", "--token", "abc"}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Errorf("Expected to execute %+v, got %+v", expected, executed)
+	}
+
+	if ran, _ := newGateway().RunIndexed([]string{"exampleapp", "--plugged-list"}); ran {
+		t.Errorf("Expected built-in commands to need the registry")
+	}
+
+	g := newGateway()
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	invocations, err := g.Invocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invocations) != 1 || !reflect.DeepEqual(invocations[0].Args, []string{"--token", redacted}) {
+		t.Errorf("Expected spooled invocation in audit log, got %+v", invocations)
+	}
+
+	// Registry updates remove the index.
+	if err := g.updateAlias("g", "find"); err != nil {
+		t.Fatal(err)
+	}
+	g.Store.Close()
+
+	if _, err := os.Stat(g.indexPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected index to be removed on update, got %v", err)
+	}
+
+	if ran, _ := newGateway().RunIndexed([]string{"exampleapp", "g"}); ran {
+		t.Errorf("Expected no dispatch with stale index")
+	}
+
+	session([]string{"exampleapp", "--plugged-uninstall", "find"})
+
+	if ran, _ := newGateway().RunIndexed([]string{"exampleapp", "find"}); ran {
+		t.Errorf("Expected no dispatch of uninstalled plugin")
+	}
+}
+
+func TestSpoolInvocations(t *testing.T) {
+	if err := os.MkdirAll("./tmp/spool", 0777); err != nil {
+		t.Fatalf("Unable to create directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/spool")
+
+	g := &GatewayT{Stdout: &bytes.Buffer{}, Home: "./tmp/spool", Name: "exampleapp"}
+
+	// Spool file is rotated once it grows too large.
+	full := bytes.Repeat([]byte("-\n"), maxSpoolSize/2+1)
+	if err := ioutil.WriteFile(g.spoolPath(), full, 0600); err != nil {
+		t.Fatalf("Unable to create spool file - %s", err)
+	}
+
+	expired := &invocationT{Time: time.Now().UTC().AddDate(0, 0, -60), Plugin: "old"}
+	recent := &invocationT{Time: time.Now().UTC(), Plugin: "find"}
+	g.spoolInvocation(expired)
+	g.spoolInvocation(recent)
+
+	if info, err := os.Stat(g.spoolPath()); err != nil || info.Size() > maxSpoolSize {
+		t.Errorf("Expected spool file to be rotated, got %v", err)
+	}
+
+	// Unspooled invocations are pruned like recorded ones.
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+
+	invocations, err := g.Invocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(invocations) != 1 || invocations[0].Plugin != "find" {
+		t.Errorf("Expected only recent invocation in audit log, got %+v", invocations)
+	}
+
+	for _, path := range []string{g.spoolPath(), g.rotatedSpoolPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package plugged
+
+import (
+	"fmt"
+	"sync"
+)
+
+// defaultInstallConcurrency is how many plugins are probed at a time during
+// installation, unless GatewayT.InstallConcurrency says otherwise.
+const defaultInstallConcurrency = 8
+
+// installT is the outcome of installing one plugin: either Plugin as it was
+// saved to the registry, or Err. Dependent is the plugin that required it,
+// when it was installed as a dependency.
+type installT struct {
+	Name      string
+	Plugin    *pluginT
+	Err       error
+	Dependent string
+}
+
+func (g *GatewayT) installConcurrency() int {
+	if g.InstallConcurrency <= 0 {
+		return defaultInstallConcurrency
+	}
+
+	return g.InstallConcurrency
+}
+
+// installPlugins probes named plugins and their dependencies concurrently
+// and saves all that were found, and have their dependencies satisfied, in
+// a single transaction. Outcomes are returned in the order of names,
+// followed by outcomes of dependencies, and the error is only returned when
+// the registry can not be updated.
+func (g *GatewayT) installPlugins(names []string) ([]*installT, error) {
+	installed, err := g.installedPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	results := g.probePlugins(names)
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = true
+	}
+
+	// Dependencies are probed level by level, so that plugins of the same
+	// level are still probed concurrently.
+	for level := results; len(level) > 0; {
+		next := []string{}
+		dependents := map[string]string{}
+
+		for _, result := range level {
+			if result.Err != nil {
+				continue
+			}
+
+			for _, dep := range result.Plugin.dependencies() {
+				if seen[dep] {
+					continue
+				}
+
+				if p, ok := installed[dep]; ok {
+					if ok, _ := versionSatisfies(p.Version, result.Plugin.Depends[dep]); ok {
+						continue
+					}
+				}
+
+				seen[dep] = true
+				dependents[dep] = result.Name
+				next = append(next, dep)
+			}
+		}
+
+		level = g.probePlugins(next)
+		for _, result := range level {
+			result.Dependent = dependents[result.Name]
+		}
+		results = append(results, level...)
+	}
+
+	checkDependencies(results, installed)
+
+	if err := g.approvePermissions(results, installed); err != nil {
+		return nil, err
+	}
+
+	err = g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("plugins")
+		if err != nil {
+			return fmt.Errorf("Unable to obtain bucket 'plugins' - %s", err)
+		}
+
+		for _, result := range results {
+			if result.Err != nil {
+				continue
+			}
+
+			if err := result.Plugin.save(b); err != nil {
+				return fmt.Errorf("Unable to save plugin to bucket 'plugins' - %s", err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to save plugins to storage - %s", err)
+	}
+
+	return results, nil
+}
+
+// installedPlugins returns plugins in the registry by name.
+func (g *GatewayT) installedPlugins() (map[string]*pluginT, error) {
+	installed := map[string]*pluginT{}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("plugins")
+		if b == nil {
+			return nil
+		}
+
+		plugins, _, err := listPlugins(b)
+		for _, p := range plugins {
+			installed[p.Name] = p
+		}
+
+		return err
+	})
+
+	return installed, err
+}
+
+// probePlugins probes named plugins concurrently, outcomes are returned in
+// the order of names.
+func (g *GatewayT) probePlugins(names []string) []*installT {
+	results := make([]*installT, len(names))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+
+	for w := 0; w < g.installConcurrency() && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				results[i] = g.probePlugin(names[i])
+			}
+		}()
+	}
+
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (g *GatewayT) probePlugin(name string) *installT {
+	if err := g.context().Err(); err != nil {
+		return &installT{Name: name, Err: err}
+	}
+
+	p := newPlugin(g.Name, name)
+	if err := p.discover(g); err != nil {
+		return &installT{Name: name, Err: err}
+	}
+
+	return &installT{Name: name, Plugin: p}
+}
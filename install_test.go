@@ -0,0 +1,86 @@
+package plugged
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// countingStore counts Update transactions of the underlying store.
+type countingStore struct {
+	Store
+	updates int
+}
+
+func (s *countingStore) Update(fn func(tx Tx) error) error {
+	s.updates++
+	return s.Store.Update(fn)
+}
+
+func TestInstallPlugins(t *testing.T) {
+	if err := os.MkdirAll("./tmp/install", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/install")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/install:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	names := []string{}
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("slow%d", i)
+		names = append(names, name)
+
+		plugin := "#!/usr/bin/env sh\nsleep 0.3\necho -n Slow plugin.\n"
+		if err := ioutil.WriteFile("./tmp/install/exampleapp-"+name, []byte(plugin), 0777); err != nil {
+			t.Fatalf("Unable to create plugin - %s", err)
+		}
+	}
+	names = append(names, "missing")
+
+	store := &countingStore{Store: NewMemoryStore()}
+	g := &GatewayT{
+		Stdout:             &bytes.Buffer{},
+		Name:               "exampleapp",
+		Store:              store,
+		InstallConcurrency: 6,
+	}
+
+	started := time.Now()
+	installs, err := g.installPlugins(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every plugin is probed twice, for description and metadata.
+	if elapsed := time.Since(started); elapsed > 6*2*300*time.Millisecond/2 {
+		t.Errorf("Expected plugins to be probed concurrently, took %s", elapsed)
+	}
+
+	if store.updates != 1 {
+		t.Errorf("Expected a single transaction, got %d", store.updates)
+	}
+
+	for i, install := range installs {
+		if install.Name != names[i] {
+			t.Errorf("Expected outcome of %s at %d, got %s", names[i], i, install.Name)
+		}
+	}
+
+	if installs[6].Err == nil {
+		t.Errorf("Expected missing plugin to fail, got %+v", installs[6].Plugin)
+	}
+
+	plugins, err := g.Plugins()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plugins) != 6 {
+		t.Errorf("Expected 6 plugins installed, got %d", len(plugins))
+	}
+}
@@ -0,0 +1,146 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// lockfileT is a snapshot of the plugin registry that can be checked in and
+// imported on another machine.
+type lockfileT struct {
+	AppName string            `json:"AppName"`
+	Plugins []*pluginT        `json:"Plugins"`
+	Aliases map[string]string `json:"Aliases"`
+}
+
+func (g *GatewayT) lockfile() (*lockfileT, error) {
+	plugins, err := g.Plugins()
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := g.Aliases()
+	if err != nil {
+		return nil, err
+	}
+
+	if plugins == nil {
+		plugins = []*pluginT{}
+	}
+
+	return &lockfileT{
+		AppName: g.Name,
+		Plugins: plugins,
+		Aliases: aliases,
+	}, nil
+}
+
+func (g *GatewayT) exportAction(_ string, args []string) error {
+	if len(args) > 1 {
+		return g.showUsage("--plugged-export [file]")
+	}
+
+	lock, err := g.lockfile()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal lockfile %+v to json - %s", lock, err)
+	}
+	data = append(data, '\n')
+
+	if len(args) == 0 {
+		if _, err := g.Stdout.Write(data); err != nil {
+			return fmt.Errorf("Unable to write lockfile to stdout - %s", err)
+		}
+
+		return nil
+	}
+
+	if err := ioutil.WriteFile(args[0], data, 0644); err != nil {
+		return fmt.Errorf("Unable to write lockfile %s - %s", args[0], err)
+	}
+
+	return nil
+}
+
+func (g *GatewayT) importAction(_ string, args []string) error {
+	if len(args) != 1 {
+		return g.showUsage("--plugged-import file")
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("Unable to read lockfile %s - %s", args[0], err)
+	}
+
+	lock := &lockfileT{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return fmt.Errorf("Unable to unmarshal lockfile %s - %s", args[0], err)
+	}
+
+	report := &importReportView{Aliases: lock.Aliases}
+
+	names := []string{}
+	for _, locked := range lock.Plugins {
+		names = append(names, locked.Name)
+	}
+
+	installs, err := g.installPlugins(names)
+	if err != nil {
+		return err
+	}
+
+	for i, locked := range lock.Plugins {
+		entry := &importEntryT{Name: locked.Name}
+		report.Entries = append(report.Entries, entry)
+
+		if installs[i].Err != nil {
+			entry.Error = installs[i].Err.Error()
+			continue
+		}
+
+		entry.Differences = lockDifferences(locked, installs[i].Plugin)
+	}
+
+	for alias, name := range lock.Aliases {
+		if err := g.updateAlias(alias, name); err != nil {
+			return err
+		}
+	}
+
+	return report.render(g.template("importReport"), g.Stdout)
+}
+
+func lockDifferences(locked, installed *pluginT) []string {
+	differences := []string{}
+
+	if locked.Version != installed.Version {
+		differences = append(differences, fmt.Sprintf(
+			"version '%s' differs from locked '%s'",
+			installed.Version,
+			locked.Version,
+		))
+	}
+
+	if locked.Checksum != installed.Checksum {
+		differences = append(differences, fmt.Sprintf(
+			"checksum %s differs from locked %s",
+			installed.Checksum,
+			locked.Checksum,
+		))
+	}
+
+	if locked.Path != installed.Path {
+		differences = append(differences, fmt.Sprintf(
+			"path %s differs from locked %s",
+			installed.Path,
+			locked.Path,
+		))
+	}
+
+	return differences
+}
@@ -0,0 +1,199 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// manifestT is a project-local list of plugins required to work with the
+// project, stored in .<app>-plugins.json file.
+type manifestT struct {
+	Path    string            `json:"-"`
+	Plugins map[string]string `json:"Plugins"`
+}
+
+// requirementT is a plugin required by the manifest, that is either not
+// installed or has version not satisfying the constraint.
+type requirementT struct {
+	Name       string
+	Constraint string
+	Installed  string
+	Problem    string
+}
+
+func (g *GatewayT) workDir() (string, error) {
+	if g.WorkDir != "" {
+		return filepath.Abs(g.WorkDir)
+	}
+
+	return os.Getwd()
+}
+
+// manifest walks up from the working directory looking for the project
+// manifest. It returns nil when there is none.
+func (g *GatewayT) manifest() (*manifestT, error) {
+	dir, err := g.workDir()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine working directory - %s", err)
+	}
+
+	for {
+		path := filepath.Join(dir, "."+g.Name+"-plugins.json")
+
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			m := &manifestT{Path: path}
+			if err := json.Unmarshal(data, m); err != nil {
+				return nil, fmt.Errorf("Unable to unmarshal manifest %s - %s", path, err)
+			}
+
+			return m, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("Unable to read manifest %s - %s", path, err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// unmetRequirements returns required plugins that are either missing or have
+// unsuitable version installed.
+func (g *GatewayT) unmetRequirements(m *manifestT) ([]*requirementT, error) {
+	unmet := []*requirementT{}
+
+	names := []string{}
+	for name := range m.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := g.Store.View(func(tx Tx) error {
+		for _, name := range names {
+			r := &requirementT{Name: name, Constraint: m.Plugins[name]}
+
+			p, err := resolvePlugin(tx, name)
+			if err != nil {
+				r.Problem = "not installed"
+				unmet = append(unmet, r)
+				continue
+			}
+
+			r.Installed = p.Version
+
+			ok, err := versionSatisfies(p.Version, r.Constraint)
+			if err != nil {
+				r.Problem = err.Error()
+			} else if !ok {
+				r.Problem = fmt.Sprintf("version '%s' does not satisfy '%s'", p.Version, r.Constraint)
+			}
+
+			if r.Problem != "" {
+				unmet = append(unmet, r)
+			}
+		}
+
+		return nil
+	})
+
+	return unmet, err
+}
+
+// checkManifest warns about plugins required by the project manifest, that
+// are not installed properly.
+func (g *GatewayT) checkManifest() error {
+	m, err := g.manifest()
+	if err != nil {
+		fmt.Fprintf(g.stderr(), "[WARNING] %s\n", err)
+		return nil
+	}
+
+	if m == nil {
+		return nil
+	}
+
+	unmet, err := g.unmetRequirements(m)
+	if err != nil {
+		return err
+	}
+
+	if len(unmet) == 0 {
+		return nil
+	}
+
+	path := m.Path
+	if dir, err := g.workDir(); err == nil {
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			path = rel
+		}
+	}
+
+	warning := &manifestWarningView{
+		AppName:      g.Name,
+		Path:         path,
+		Requirements: unmet,
+	}
+
+	return warning.render(g.template("manifestWarning"), g.stderr())
+}
+
+func (g *GatewayT) syncAction(_ string, _ []string) error {
+	m, err := g.manifest()
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		fmt.Fprintf(g.Stdout, "No .%s-plugins.json manifest found.\n", g.Name)
+		return nil
+	}
+
+	unmet, err := g.unmetRequirements(m)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, r := range unmet {
+		names = append(names, r.Name)
+	}
+
+	installs, err := g.installPlugins(names)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range unmet {
+		if installs[i].Err != nil {
+			fmt.Fprintf(g.Stdout, "%s: Failed to install - %s\n", r.Name, installs[i].Err)
+			continue
+		}
+
+		p := installs[i].Plugin
+
+		ok, err := versionSatisfies(p.Version, r.Constraint)
+		if err != nil || !ok {
+			fmt.Fprintf(
+				g.Stdout,
+				"%s: Installed version '%s' does not satisfy '%s'\n",
+				r.Name,
+				p.Version,
+				r.Constraint,
+			)
+			continue
+		}
+
+		fmt.Fprintf(g.Stdout, "%s: Installed version '%s'\n", r.Name, p.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package plugged
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// migrationT upgrades the registry from the previous schema version. Every
+// migration runs in its own transaction together with the version bump.
+type migrationT struct {
+	Name string
+	Up   func(tx Tx) error
+}
+
+// migrations are applied in order, so schema version is the number of
+// migrations applied. Never reorder or remove them, only append new ones.
+var migrations = []migrationT{
+	{
+		Name: "normalize plugin records",
+		Up: func(tx Tx) error {
+			b := tx.Bucket("plugins")
+			if b == nil {
+				return nil
+			}
+
+			records := map[string]*pluginT{}
+			err := b.ForEach(func(key, data []byte) error {
+				// Broken records are left to --plugged-doctor --repair.
+				if plugin, err := decodePlugin(data, string(key)); err == nil {
+					records[string(key)] = plugin
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, plugin := range records {
+				if err := plugin.save(b); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+}
+
+func schemaVersion(tx Tx) (int, error) {
+	b := tx.Bucket("meta")
+	if b == nil {
+		return 0, nil
+	}
+
+	data := b.Get([]byte("schema_version"))
+	if data == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse schema version '%s' - %s", data, err)
+	}
+
+	return version, nil
+}
+
+func migrate(store Store) error {
+	var current int
+
+	err := store.View(func(tx Tx) error {
+		var err error
+		current, err = schemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if current > len(migrations) {
+		return fmt.Errorf(
+			"Plugin registry has schema version %d, but only %d is supported - upgrade the application",
+			current,
+			len(migrations),
+		)
+	}
+
+	for version := current; version < len(migrations); version++ {
+		migration := migrations[version]
+
+		err := store.Update(func(tx Tx) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			b, err := tx.CreateBucketIfNotExists("meta")
+			if err != nil {
+				return fmt.Errorf("Unable to obtain bucket 'meta' - %s", err)
+			}
+
+			return b.Put([]byte("schema_version"), []byte(strconv.Itoa(version+1)))
+		})
+
+		if err != nil {
+			return fmt.Errorf("Unable to apply migration %d '%s' - %s", version+1, migration.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package plugged
+
+import (
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	store := NewMemoryStore()
+
+	err := store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("plugins")
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte("find"), []byte(`{"Name":"find","Description":"Find some stuff.","AppName":"exampleapp"}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(store); err != nil {
+		t.Fatalf("Unable to migrate - %s", err)
+	}
+
+	err = store.View(func(tx Tx) error {
+		version, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		if version != len(migrations) {
+			t.Errorf("Expected schema version %d, got %d", len(migrations), version)
+		}
+
+		if _, err := pluginFrom(tx.Bucket("plugins"), "find"); err != nil {
+			t.Errorf("Expected plugin to survive migrations - %s", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Update(func(tx Tx) error {
+		return tx.Bucket("meta").Put([]byte("schema_version"), []byte("999"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrate(store); err == nil {
+		t.Errorf("Expected migrating registry from newer version to fail")
+	}
+}
@@ -0,0 +1,76 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// errorT is how errors of built-in commands are reported in JSON mode.
+type errorT struct {
+	Message string `json:"Message"`
+	Usage   string `json:"Usage,omitempty"`
+}
+
+// renderedError is returned by actions that have already reported the
+// failure in their output, so it is not reported once more.
+type renderedError struct {
+	error
+}
+
+// extractGatewayFlags removes flags common for all built-in commands from
+// their arguments and applies them to the gateway: "--output <format>" (or
+// "--output=<format>") and "--no-color".
+func (g *GatewayT) extractGatewayFlags(args []string) ([]string, error) {
+	output := outputText
+	noColor := false
+	rest := []string{}
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--output" && i+1 < len(args):
+			i++
+			output = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			output = strings.TrimPrefix(args[i], "--output=")
+		case args[i] == "--no-color":
+			noColor = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	if output != outputText && output != outputJSON {
+		return nil, fmt.Errorf("Unknown output format '%s', expected text or json", output)
+	}
+
+	g.output = output
+	g.noColor = noColor
+	return rest, nil
+}
+
+func isGatewayFlag(arg string) bool {
+	return arg == "--output" || strings.HasPrefix(arg, "--output=") || arg == "--no-color"
+}
+
+func (g *GatewayT) renderJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal %+v to json - %s", v, err)
+	}
+
+	if _, err := g.Stdout.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("Unable to write json to stdout - %s", err)
+	}
+
+	return nil
+}
+
+func (g *GatewayT) renderError(e *errorT) error {
+	return g.renderJSON(map[string]*errorT{"Error": e})
+}
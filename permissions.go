@@ -0,0 +1,282 @@
+package plugged
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// permissionsT is what plugin needs besides running its own binary, when
+// the gateway runs plugins sandboxed.
+type permissionsT struct {
+	// Env are names of environment variables plugin receives, besides
+	// sandboxEnv ones.
+	Env []string `json:"Env,omitempty"`
+
+	// Network is access to the network, it is only enforced with
+	// SandboxHelper.
+	Network bool `json:"Network,omitempty"`
+
+	// Write are paths plugin can write to, "~/" standing for the home
+	// directory. Everything else is read-only with SandboxHelper.
+	Write []string `json:"Write,omitempty"`
+
+	// Dirs are the only directories WebAssembly plugin can access, they
+	// are requested with metadataT.Dirs.
+	Dirs []string `json:"Dirs,omitempty"`
+}
+
+// permissionError is why plugin can not be installed when user does not
+// approve permissions it requests, as opposed to failing to get its
+// metadata.
+type permissionError struct {
+	message string
+}
+
+func (e *permissionError) Error() string {
+	return e.message
+}
+
+// sandboxEnv are environment variables every sandboxed plugin receives.
+var sandboxEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TERM", "TZ", "TMPDIR"}
+
+func (p *permissionsT) empty() bool {
+	return p == nil || len(p.Env) == 0 && !p.Network && len(p.Write) == 0 && len(p.Dirs) == 0
+}
+
+func (p *permissionsT) equal(other *permissionsT) bool {
+	if p.empty() || other.empty() {
+		return p.empty() == other.empty()
+	}
+
+	return strings.Join(p.Env, "\n") == strings.Join(other.Env, "\n") &&
+		p.Network == other.Network &&
+		strings.Join(p.Write, "\n") == strings.Join(other.Write, "\n") &&
+		strings.Join(p.Dirs, "\n") == strings.Join(other.Dirs, "\n")
+}
+
+func (p *permissionsT) describe() string {
+	lines := []string{}
+
+	if len(p.Env) > 0 {
+		lines = append(lines, "- read environment variables: "+strings.Join(p.Env, ", "))
+	}
+
+	if p.Network {
+		lines = append(lines, "- access the network")
+	}
+
+	if len(p.Write) > 0 {
+		lines = append(lines, "- write to: "+strings.Join(p.Write, ", "))
+	}
+
+	if len(p.Dirs) > 0 {
+		lines = append(lines, "- access directories: "+strings.Join(p.Dirs, ", "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// writePaths returns absolute paths plugin can write to.
+func (p *permissionsT) writePaths(home string) []string {
+	paths := []string{}
+	if p == nil {
+		return paths
+	}
+
+	for _, path := range p.Write {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+
+		if abs, err := filepath.Abs(path); err == nil {
+			paths = append(paths, abs)
+		}
+	}
+
+	return paths
+}
+
+// dirs returns directories WebAssembly plugin can access.
+func (p *permissionsT) dirs() []string {
+	if p == nil {
+		return nil
+	}
+
+	return p.Dirs
+}
+
+// requested returns permissions plugin requests: its Permissions, together
+// with access to its Dirs.
+func (p *pluginT) requested() *permissionsT {
+	if len(p.Dirs) == 0 {
+		return p.Permissions
+	}
+
+	requested := &permissionsT{}
+	if p.Permissions != nil {
+		*requested = *p.Permissions
+	}
+	requested.Dirs = append(append([]string{}, requested.Dirs...), p.Dirs...)
+
+	return requested
+}
+
+// environ returns entries of environment that plugin with approved
+// permissions receives.
+func (p *permissionsT) environ(environ []string) []string {
+	allowed := map[string]bool{}
+	for _, name := range sandboxEnv {
+		allowed[name] = true
+	}
+
+	if p != nil {
+		for _, name := range p.Env {
+			allowed[name] = true
+		}
+	}
+
+	result := []string{}
+	for _, entry := range environ {
+		if allowed[strings.SplitN(entry, "=", 2)[0]] {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+// approvePermissions asks user to approve permissions requested by
+// plugins being installed, unless the same permissions were approved when
+// they were installed before. Plugins whose permissions are not approved
+// fail to install.
+func (g *GatewayT) approvePermissions(installs []*installT, installed map[string]*pluginT) error {
+	if !g.Sandbox {
+		return nil
+	}
+
+	var stdin *bufio.Reader
+	if g.Stdin != nil {
+		stdin = bufio.NewReader(g.Stdin)
+	}
+
+	for _, install := range installs {
+		if install.Err != nil {
+			continue
+		}
+
+		p := install.Plugin
+		requested := p.requested()
+		if previous, ok := installed[p.Name]; requested.empty() || ok && requested.equal(previous.Approved) {
+			p.Approved = requested
+			continue
+		}
+
+		ok, err := g.confirmPermissions(stdin, p)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			install.Err = &permissionError{message: "Permissions were not approved"}
+			install.Plugin = nil
+			continue
+		}
+
+		p.Approved = requested
+	}
+
+	return nil
+}
+
+// confirmPermissions asks on stderr, so that JSON output stays intact.
+func (g *GatewayT) confirmPermissions(stdin *bufio.Reader, p *pluginT) (bool, error) {
+	fmt.Fprintf(g.stderr(), "Plugin '%s' requests permission to:\n%s\nApprove? [y/N] ", p.Name, p.requested().describe())
+
+	if stdin == nil {
+		fmt.Fprintln(g.stderr())
+		return false, nil
+	}
+
+	answer, err := readLine(stdin)
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// sandboxDir is the private working directory of sandboxed plugin, it is
+// kept between invocations.
+func (g *GatewayT) sandboxDir(p *pluginT) (string, error) {
+	dir, err := filepath.Abs(filepath.Join(g.Home, "."+g.Name+"-sandbox", p.Name))
+	if err != nil {
+		return "", fmt.Errorf("Unable to locate sandbox for plugin '%s' - %s", p.Name, err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("Unable to create sandbox for plugin '%s' - %s", p.Name, err)
+	}
+
+	return dir, nil
+}
+
+// launchT is how plugin binary is run, see GatewayT.launch.
+type launchT struct {
+	Binary string
+	Argv   []string
+	Env    []string
+
+	// Dir is the private working directory of sandboxed plugin, it is
+	// empty for plugins running in the working directory, and for
+	// WebAssembly ones, as they can only access their Dirs anyway.
+	Dir string
+}
+
+// sandboxed makes launch of plugin sandboxed, confining plugin with
+// SandboxHelper when configured. Environment is scrubbed down to approved
+// variables by environ.
+func (g *GatewayT) sandboxed(p *pluginT, launch *launchT) error {
+	if requested := p.requested(); !requested.empty() && !requested.equal(p.Approved) {
+		return fmt.Errorf("Permissions of plugin '%s' were not approved, install it again to approve them", p.Name)
+	}
+
+	if isWasm(launch.Binary) {
+		return nil
+	}
+
+	dir, err := g.sandboxDir(p)
+	if err != nil {
+		return err
+	}
+	launch.Dir = dir
+
+	if err := g.confine(launch, p.Approved); err != nil {
+		return fmt.Errorf("Unable to sandbox plugin '%s' - %s", p.Name, err)
+	}
+
+	return nil
+}
+
+// enter changes working directory to the sandbox one, and returns function
+// changing it back for when plugin runs as a child process. Commands set
+// exec.Cmd.Dir instead, see GatewayT.pluginCommand.
+func (s *launchT) enter() (func(), error) {
+	if s.Dir == "" {
+		return func() {}, nil
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get working directory - %s", err)
+	}
+
+	if err := os.Chdir(s.Dir); err != nil {
+		return nil, fmt.Errorf("Unable to enter sandbox %s - %s", s.Dir, err)
+	}
+
+	return func() { os.Chdir(previous) }, nil
+}
@@ -0,0 +1,218 @@
+package plugged
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pickedT is a plugin matching the filter of the interactive picker.
+type pickedT struct {
+	Number int
+	Plugin *pluginT
+	score  int
+}
+
+// pickAction lets user choose a plugin to run and its arguments, reading
+// answers line by line from Stdin.
+func (g *GatewayT) pickAction(appArg string) error {
+	plugins, err := g.Plugins()
+	if err != nil {
+		return err
+	}
+
+	visible := []*pluginT{}
+	for _, p := range plugins {
+		if !p.Hidden {
+			visible = append(visible, p)
+		}
+	}
+
+	if len(visible) == 0 {
+		return g.helpAction("help", nil)
+	}
+
+	input := bufio.NewReader(g.Stdin)
+	matches := fuzzyFilter("", visible)
+
+	for {
+		if err := g.showPicked(matches); err != nil {
+			return err
+		}
+
+		fmt.Fprint(g.Stdout, "Filter or number (empty to quit): ")
+		answer, err := readLine(input)
+		if err != nil {
+			return err
+		}
+
+		if answer == "" {
+			return nil
+		}
+
+		var chosen *pluginT
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(matches) {
+			chosen = matches[n-1].Plugin
+		} else if matches = fuzzyFilter(answer, visible); len(matches) == 1 {
+			chosen = matches[0].Plugin
+		}
+
+		if chosen == nil {
+			if len(matches) == 0 {
+				fmt.Fprintf(g.Stdout, "No commands match '%s'.\n", answer)
+				matches = fuzzyFilter("", visible)
+			}
+			continue
+		}
+
+		fmt.Fprintf(g.Stdout, "Arguments for %s: ", chosen.Name)
+		line, err := readLine(input)
+		if err != nil {
+			return err
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			return err
+		}
+
+		return g.Run(append([]string{appArg, chosen.Name}, args...))
+	}
+}
+
+func (g *GatewayT) showPicked(matches []*pickedT) error {
+	for i, m := range matches {
+		m.Number = i + 1
+	}
+
+	view := &pickerView{Matches: matches}
+	return view.render(g.template("picker"), g.Stdout)
+}
+
+// fuzzyFilter returns plugins, which names contain all characters of
+// pattern in the same order, best matches first. Plugins matching pattern
+// only in their description are returned last.
+func fuzzyFilter(pattern string, plugins []*pluginT) []*pickedT {
+	pattern = strings.ToLower(pattern)
+	matches := []*pickedT{}
+
+	for _, p := range plugins {
+		score, ok := fuzzyScore(pattern, strings.ToLower(p.Name))
+		if !ok {
+			if pattern == "" || !strings.Contains(strings.ToLower(p.Description), pattern) {
+				continue
+			}
+			score = len(p.Name) + 1
+		}
+
+		matches = append(matches, &pickedT{Plugin: p, score: score})
+	}
+
+	sort.Stable(pickedByScore(matches))
+	return matches
+}
+
+// fuzzyScore is the number of characters skipped in text while matching
+// pattern as a subsequence, so that lower score is a better match.
+func fuzzyScore(pattern, text string) (int, bool) {
+	score := 0
+	position := 0
+
+	for _, c := range pattern {
+		i := strings.IndexRune(text[position:], c)
+		if i < 0 {
+			return 0, false
+		}
+
+		score += i
+		position += i + len(string(c))
+	}
+
+	return score, true
+}
+
+type pickedByScore []*pickedT
+
+func (p pickedByScore) Len() int           { return len(p) }
+func (p pickedByScore) Less(i, j int) bool { return p[i].score < p[j].score }
+func (p pickedByScore) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Unable to read from stdin - %s", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// splitArgs splits line into arguments the way shell would do it for simple
+// cases: by whitespace, respecting single and double quotes, and escaping
+// with backslash.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	current := ""
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			current += string(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current += string(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current)
+				current = ""
+				inArg = false
+			}
+		default:
+			current += string(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("Unterminated quote or escape in '%s'", line)
+	}
+
+	if inArg {
+		args = append(args, current)
+	}
+
+	return args, nil
+}
+
+// joinArgs is the reverse of splitArgs, it quotes arguments that would be
+// split otherwise.
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\") {
+			quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,118 @@
+package plugged
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPickAction(t *testing.T) {
+	if err := os.MkdirAll("./tmp/picker", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/picker")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/picker:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	for _, name := range []string{"find", "deploy", "debug"} {
+		path := "./tmp/picker/exampleapp-" + name
+		if err := ioutil.WriteFile(path, []byte("#!/usr/bin/env sh\n"), 0777); err != nil {
+			t.Fatalf("Unable to create file %s - %s", path, err)
+		}
+	}
+
+	var executed []string
+	stdout := &bytes.Buffer{}
+	g := &GatewayT{
+		Stdin:  bytes.NewBufferString("de\nxyz\n2\n--force 'two words'\n"),
+		Stdout: stdout,
+		Name:   "exampleapp",
+		Store:  NewMemoryStore(),
+		ExecFn: func(_ string, args []string, _ []string) error {
+			executed = args
+			return nil
+		},
+	}
+
+	err := g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("plugins")
+		if err != nil {
+			return err
+		}
+
+		for _, p := range []*pluginT{
+			{Name: "find", Description: "Find some stuff.", AppName: "exampleapp"},
+			{Name: "deploy", Description: "Deploy stuff.", AppName: "exampleapp"},
+			{Name: "debug", Description: "Debug stuff.", AppName: "exampleapp"},
+		} {
+			if err := p.save(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.pickAction("exampleapp"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedArgs := []string{"exampleapp-deploy", "--force", "two words"}
+	if !reflect.DeepEqual(executed, expectedArgs) {
+		t.Errorf("Expected to execute %+v, got %+v", expectedArgs, executed)
+	}
+
+	expected := strings.TrimSuffix(dedent(`
+              |1) debug  - Debug stuff.
+              |2) deploy - Deploy stuff.
+              |3) find   - Find some stuff.
+              |Filter or number (empty to quit): 1) debug  - Debug stuff.
+              |2) deploy - Deploy stuff.
+              |Filter or number (empty to quit): No commands match 'xyz'.
+              |1) debug  - Debug stuff.
+              |2) deploy - Deploy stuff.
+              |3) find   - Find some stuff.
+              |Filter or number (empty to quit): Arguments for deploy: `), "\n")
+
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected:\n%q\nActual:\n%q", expected, actual)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	examples := map[string][]string{
+		``:                       {},
+		`one  two`:               {"one", "two"},
+		`"two words" 'and more'`: {"two words", "and more"},
+		`it\'s "a \"quote\""`:    {"it's", `a "quote"`},
+		`'' end`:                 {"", "end"},
+	}
+
+	for line, expected := range examples {
+		actual, err := splitArgs(line)
+		if err != nil {
+			t.Errorf("Unable to split %q - %s", line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected %q to split into %q, got %q", line, expected, actual)
+		}
+	}
+
+	if _, err := splitArgs(`"unterminated`); err == nil {
+		t.Errorf("Expected error for unterminated quote")
+	}
+}
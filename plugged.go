@@ -2,15 +2,32 @@
 package plugged
 
 import (
+	"context"
 	"fmt"
 	"io"
-
-	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 )
 
 var builtinHandlers = map[string]actionHandler{
-	"help":              actionHandler((*GatewayT).helpAction),
-	"--plugged-install": actionHandler((*GatewayT).installAction),
+	"help":                actionHandler((*GatewayT).helpAction),
+	"--plugged-install":   actionHandler((*GatewayT).installAction),
+	"--plugged-alias":     actionHandler((*GatewayT).aliasAction),
+	"--plugged-export":    actionHandler((*GatewayT).exportAction),
+	"--plugged-import":    actionHandler((*GatewayT).importAction),
+	"--plugged-sync":      actionHandler((*GatewayT).syncAction),
+	"--plugged-doctor":    actionHandler((*GatewayT).doctorAction),
+	"--plugged-list":      actionHandler((*GatewayT).listAction),
+	"--plugged-search":    actionHandler((*GatewayT).searchAction),
+	"--plugged-docs":      actionHandler((*GatewayT).docsAction),
+	"--plugged-stats":     actionHandler((*GatewayT).statsAction),
+	"--plugged-uninstall": actionHandler((*GatewayT).uninstallAction),
+	"--plugged-env":       actionHandler((*GatewayT).envAction),
+	"--plugged-workflow":  actionHandler((*GatewayT).workflowAction),
 }
 
 type actionHandler func(g *GatewayT, action string, args []string) error
@@ -19,20 +36,165 @@ type actionHandler func(g *GatewayT, action string, args []string) error
 type GatewayT struct {
 	Stdin       io.Reader
 	Stdout      io.Writer
+	Stderr      io.Writer
 	Home        string
 	Name        string
 	Description string
 	ExecFn      func(string, []string, []string) error
 
-	store *bolt.DB
+	// Store keeps the plugin registry. When it is nil, Connect opens bolt
+	// database at Home.
+	Store Store
+
+	// WorkDir is where the project manifest lookup starts. Defaults to
+	// the current working directory.
+	WorkDir string
+
+	// Templates override view templates by name, eg. "gatewayHelp",
+	// "commandList" or "missingPlugin". Templates not found here are
+	// looked up as <name>.tmpl files in TemplateDir.
+	Templates     map[string]string
+	TemplateDir   string
+	TemplateFuncs template.FuncMap
+
+	// BuiltinCategories put built-in commands into categories in help
+	// output, eg. {"help": "Other"}.
+	BuiltinCategories map[string]string
+
+	// AuditRetention is how long plugin invocations are kept in the audit
+	// log. Defaults to defaultAuditRetention, negative value turns the
+	// audit log off.
+	AuditRetention time.Duration
+
+	// RedactPatterns mask matching parts of arguments and error messages
+	// in output and audit log. When pattern has a group, only the first
+	// group is masked, eg. `token=(\S+)`.
+	RedactPatterns []*regexp.Regexp
+
+	// ProbeTimeout bounds how long plugins can take to answer probes like
+	// --plugged-description. Defaults to defaultProbeTimeout.
+	ProbeTimeout time.Duration
+
+	// InstallConcurrency is how many plugins are probed at a time during
+	// installation. Defaults to defaultInstallConcurrency.
+	InstallConcurrency int
+
+	// PluginDirs are searched for plugins before PATH.
+	PluginDirs []string
+
+	// Interpreters run script plugins by extension, eg.
+	// {".py": {"python3", "-u"}}, in addition to defaultInterpreters.
+	Interpreters map[string][]string
+
+	// Interactive lets user pick a plugin to run, when the gateway is
+	// invoked without arguments and both Stdin and Stdout are terminals.
+	Interactive bool
+
+	// Executable is the gateway command plugins call other plugins with,
+	// see GatewayClientT. Defaults to the current executable.
+	Executable string
+
+	// BuiltinWorkflows chain commands under a name, eg.
+	// {"release": "lint && test && build --prod ; publish"}. Workflows
+	// defined with --plugged-workflow take precedence.
+	BuiltinWorkflows map[string]string
+
+	// Sandbox runs plugins with environment scrubbed down to variables
+	// they have permission to read, in a private working directory. User
+	// approves permissions plugins request when installing them.
+	Sandbox bool
+
+	// SandboxHelper, eg. "bwrap", further confines sandboxed plugins on
+	// Linux to their network and write permissions, using bubblewrap.
+	SandboxHelper string
+
+	ctx              context.Context
+	interrupts       *interruptsT
+	connectErr       error
+	runningWorkflows []string
+	clients          map[string]*clientT
+	indexed          bool
+	output           string
+	noColor          bool
+	templates        map[string]*template.Template
+}
+
+// commandT describes a command available in the gateway, as shown in JSON
+// help output.
+type commandT struct {
+	Name        string `json:"Name"`
+	Description string `json:"Description"`
+	Category    string `json:"Category,omitempty"`
+	Builtin     bool   `json:"Builtin"`
+	Workflow    bool   `json:"Workflow,omitempty"`
+}
+
+// commandGroupT is a category of commands in help output.
+type commandGroupT struct {
+	Name     string
+	Commands []*commandT
+}
+
+// helpT is JSON help output.
+type helpT struct {
+	Name        string      `json:"Name"`
+	Description string      `json:"Description"`
+	Commands    []*commandT `json:"Commands"`
+}
+
+// installResultT is JSON output of --plugged-install.
+type installResultT struct {
+	Installed []*pluginT        `json:"Installed"`
+	Failed    map[string]string `json:"Failed"`
+}
+
+// uninstallResultT is JSON output of --plugged-uninstall.
+type uninstallResultT struct {
+	Uninstalled []string          `json:"Uninstalled"`
+	Failed      map[string]string `json:"Failed"`
 }
 
 // Run is for executing a command according to provided arguments.
 func (g *GatewayT) Run(args []string) error {
+	return g.redactError(g.run(args))
+}
+
+func (g *GatewayT) run(args []string) error {
+	if err := g.context().Err(); err != nil {
+		return err
+	}
+
+	appArg := args[0]
+	interactive := len(args) == 1 && g.Interactive && isTerminal(g.Stdin) && isTerminal(g.Stdout)
 	action, args := argsToAction(args)
 
+	if err := g.loadTemplates(); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(action, "--plugged-") {
+		if err := g.checkManifest(); err != nil {
+			return err
+		}
+	}
+
+	g.output, g.noColor = outputText, false
+
+	if interactive {
+		return g.pickAction(appArg)
+	}
+
 	if handler, ok := builtinHandlers[action]; ok {
+		args, err := g.extractGatewayFlags(args)
+		if err != nil {
+			return err
+		}
+
 		if err := handler(g, action, args); err != nil {
+			if _, ok := err.(renderedError); !ok && g.output == outputJSON {
+				g.renderError(&errorT{Message: g.redact(err.Error())})
+			}
+
 			return err
 		}
 
@@ -42,68 +204,180 @@ func (g *GatewayT) Run(args []string) error {
 	return g.runPlugin(action, args)
 }
 
+// Connect opens the default bolt store unless Store was already provided,
+// and migrates it to the current schema version. When it fails,
+// --plugged-doctor can still be run to report the problem.
 func (g *GatewayT) Connect() error {
-	var err error
-
-	g.store, err = bolt.Open(g.Home+"/."+g.Name+".db", 0600, nil)
-	if err != nil {
-		return fmt.Errorf("Unable to connect to embedded database - %s", err)
-	}
-
-	return nil
+	return g.ConnectContext(context.Background())
 }
 
+// Disconnect stops plugins started by Dial, regenerates the dispatch index,
+// if the registry changed, and closes the Store. The index is only an
+// optimization, so failing to write it is not an error.
 func (g *GatewayT) Disconnect() {
-	g.store.Close()
+	g.closeClients()
+	if g.Store == nil {
+		return
+	}
+
+	g.writeIndex()
+	g.Store.Close()
 }
 
 func (g *GatewayT) Plugins() ([]*pluginT, error) {
 	var plugins []*pluginT
 
-	err := g.store.View(func(tx *bolt.Tx) error {
+	err := g.Store.View(func(tx Tx) error {
 		var err error
 
-		b := tx.Bucket([]byte("plugins"))
+		b := tx.Bucket("plugins")
 		if b == nil {
 			return nil
 		}
 
-		if plugins, err = listPlugins(b); err != nil {
+		var broken []string
+		if plugins, broken, err = listPlugins(b); err != nil {
 			return fmt.Errorf("Unable to get plugins - %s", err)
 		}
 
+		for _, name := range broken {
+			fmt.Fprintf(
+				g.stderr(),
+				"[ERROR] Plugin '%s' has corrupted registry entry, run '%s --plugged-doctor --repair'\n",
+				name,
+				g.Name,
+			)
+		}
+
 		return nil
 	})
 
 	return plugins, err
 }
 
-func (g *GatewayT) updatePlugin(p *pluginT) error {
-	return g.store.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte("plugins"))
+func (g *GatewayT) removePlugin(name string) error {
+	return g.Store.Update(func(tx Tx) error {
+		b := tx.Bucket("plugins")
+		if b == nil {
+			return nil
+		}
+
+		if err := b.Delete([]byte(name)); err != nil {
+			return fmt.Errorf("Unable to remove plugin from bucket 'plugins' - %s", err)
+		}
+
+		return nil
+	})
+}
+
+// Aliases returns all aliases mapped to names of plugins they stand for.
+func (g *GatewayT) Aliases() (map[string]string, error) {
+	aliases := map[string]string{}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("aliases")
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(alias, name []byte) error {
+			aliases[string(alias)] = string(name)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get aliases - %s", err)
+	}
+
+	return aliases, nil
+}
+
+func (g *GatewayT) updateAlias(alias, name string) error {
+	return g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("aliases")
 		if err != nil {
-			return fmt.Errorf("Unable to obtain bucket 'plugins' - %s", err)
+			return fmt.Errorf("Unable to obtain bucket 'aliases' - %s", err)
 		}
 
-		if err := p.save(b); err != nil {
-			return fmt.Errorf("Unable to save plugin to bucket 'plugins' - %s", err)
+		if err := b.Put([]byte(alias), []byte(name)); err != nil {
+			return fmt.Errorf("Unable to save alias to bucket 'aliases' - %s", err)
 		}
 
 		return nil
 	})
 }
 
-func (g *GatewayT) helpAction(string, []string) error {
+func (g *GatewayT) helpAction(_ string, args []string) error {
+	all := false
+	for _, arg := range args {
+		if arg == "--all" {
+			all = true
+		}
+	}
+
 	plugins, err := g.Plugins()
 	if err != nil {
 		return err
 	}
 
+	visible := []*pluginT{}
+	for _, p := range plugins {
+		if all || !p.Hidden {
+			visible = append(visible, p)
+		}
+	}
+
+	commands := []*commandT{}
+	for _, p := range visible {
+		commands = append(commands, &commandT{
+			Name:        p.Name,
+			Description: p.Description,
+			Category:    p.Category,
+		})
+	}
+
+	workflows, err := g.Workflows()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		commands = append(commands, &commandT{
+			Name:        name,
+			Description: "Runs " + g.redactDefinition(name, workflows[name]),
+			Workflow:    true,
+		})
+	}
+
+	commands = append(commands, &commandT{
+		Name:        "help",
+		Description: "This info.",
+		Category:    g.BuiltinCategories["help"],
+		Builtin:     true,
+	})
+
+	if g.output == outputJSON {
+		return g.renderJSON(&helpT{
+			Name:        g.Name,
+			Description: g.Description,
+			Commands:    commands,
+		})
+	}
+
 	commandList := &commandListView{
-		PluginList: plugins,
+		PluginList:       visible,
+		Groups:           groupCommands(commands),
+		DescriptionWidth: descriptionWidth(outputWidth(g.Stdout), commands),
 	}
 
-	availableCommands, err := commandList.render()
+	availableCommands, err := commandList.render(g.template("commandList"))
 	if err != nil {
 		return err
 	}
@@ -114,51 +388,228 @@ func (g *GatewayT) helpAction(string, []string) error {
 		AvailableCommands: availableCommands,
 	}
 
-	if err := help.render(g.Stdout); err != nil {
+	if err := help.render(g.template("gatewayHelp"), g.Stdout); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (g *GatewayT) installAction(_ string, plugins []string) error {
-	for _, name := range plugins {
-		p := newPlugin(g.Name, name)
+	result := &installResultT{
+		Installed: []*pluginT{},
+		Failed:    map[string]string{},
+	}
 
-		if err := p.install(g); err != nil {
-			fmt.Printf("%s: Failed to get metadata - %s\n", name, err)
+	installs, err := g.installPlugins(plugins)
+	if err != nil {
+		return err
+	}
+
+	for _, install := range installs {
+		if install.Err != nil {
+			result.Failed[install.Name] = install.Err.Error()
+
+			if g.output != outputJSON {
+				reason := "Failed to get metadata"
+				switch install.Err.(type) {
+				case *dependencyError, *permissionError:
+					reason = "Failed to install"
+				}
+
+				fmt.Fprintf(g.Stdout, "%s: %s - %s\n", install.Name, reason, install.Err)
+			}
+			continue
 		}
+
+		result.Installed = append(result.Installed, install.Plugin)
+
+		if install.Dependent != "" && g.output != outputJSON {
+			fmt.Fprintf(g.Stdout, "%s: Installed as a dependency of %s\n", install.Name, install.Dependent)
+		}
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(result)
 	}
 
 	return nil
 }
 
-func (g *GatewayT) runPlugin(name string, args []string) error {
-	err := g.store.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("plugins"))
-		if b == nil {
-			return fmt.Errorf("There are no plugins installed")
-		}
+func (g *GatewayT) uninstallAction(_ string, names []string) error {
+	if len(names) == 0 {
+		return g.showUsage("--plugged-uninstall plugin [plugin ...]")
+	}
 
-		plugin, err := pluginFrom(b, name)
-		if err != nil {
-			return err
+	result := &uninstallResultT{
+		Uninstalled: []string{},
+		Failed:      map[string]string{},
+	}
+
+	uninstalling := map[string]bool{}
+	for _, name := range names {
+		uninstalling[name] = true
+	}
+
+	err := g.Store.Update(func(tx Tx) error {
+		plugins := tx.Bucket("plugins")
+		aliases := tx.Bucket("aliases")
+
+		for _, name := range names {
+			if plugins == nil || plugins.Get([]byte(name)) == nil {
+				result.Failed[name] = "Plugin is not installed"
+				continue
+			}
+
+			found, err := dependents(plugins, name)
+			if err != nil {
+				return err
+			}
+
+			required := []string{}
+			for _, dependent := range found {
+				if !uninstalling[dependent] {
+					required = append(required, dependent)
+				}
+			}
+
+			if len(required) > 0 {
+				result.Failed[name] = fmt.Sprintf("Plugin is required by %s", strings.Join(required, ", "))
+				continue
+			}
+
+			if err := plugins.Delete([]byte(name)); err != nil {
+				return fmt.Errorf("Unable to remove plugin from bucket 'plugins' - %s", err)
+			}
+
+			if err := removeAliasesOf(aliases, name); err != nil {
+				return err
+			}
+
+			result.Uninstalled = append(result.Uninstalled, name)
 		}
 
-		if err := plugin.run(g.ExecFn, args); err != nil {
-			return err
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(result)
+	}
+
+	for _, name := range names {
+		if reason, ok := result.Failed[name]; ok {
+			fmt.Fprintf(g.Stdout, "%s: Failed to uninstall - %s\n", name, reason)
+			continue
 		}
 
+		fmt.Fprintf(g.Stdout, "%s: Uninstalled\n", name)
+	}
+
+	return nil
+}
+
+// removeAliasesOf removes all aliases pointing to the named plugin.
+func removeAliasesOf(aliases Bucket, name string) error {
+	if aliases == nil {
+		return nil
+	}
+
+	stale := [][]byte{}
+	err := aliases.ForEach(func(alias, target []byte) error {
+		if string(target) == name {
+			stale = append(stale, alias)
+		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range stale {
+		if err := aliases.Delete(alias); err != nil {
+			return fmt.Errorf("Unable to remove alias from bucket 'aliases' - %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (g *GatewayT) listAction(_ string, args []string) error {
+	if len(args) > 0 {
+		return g.showUsage("--plugged-list")
+	}
+
+	plugins, err := g.Plugins()
+	if err != nil {
+		return err
+	}
+
+	if g.output == outputJSON {
+		if plugins == nil {
+			plugins = []*pluginT{}
+		}
+
+		return g.renderJSON(plugins)
+	}
+
+	list := &pluginListView{PluginList: plugins}
+	return list.render(g.template("pluginList"), g.Stdout)
+}
+
+func (g *GatewayT) aliasAction(_ string, args []string) error {
+	if len(args) != 2 {
+		return g.showUsage("--plugged-alias alias plugin")
+	}
+
+	if err := g.updateAlias(args[0], args[1]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (g *GatewayT) runPlugin(name string, args []string) error {
+	var plugin *pluginT
+	err := g.Store.View(func(tx Tx) error {
+		var err error
+		plugin, err = resolvePlugin(tx, name)
+		return err
+	})
+
+	// Installed plugins and aliases take precedence over workflows.
+	if err != nil {
+		if workflows, wfErr := g.Workflows(); wfErr == nil && workflows[name] != "" {
+			return g.runWorkflow(name, workflows[name], args)
+		}
+	}
+
+	// Plugin is run outside of the transaction, as it can take long, and
+	// the audit log needs to be updated meanwhile.
+	if err == nil {
+		invocation := g.startInvocation(plugin, args)
+
+		// Plugin usually replaces the process, so the index is brought
+		// up to date with the audit log entry just written.
+		g.writeIndex()
+
+		err = plugin.run(g, args)
+		g.finishInvocation(invocation, err)
+	}
+
+	if exitErr, ok := err.(*ExitError); ok {
+		return exitErr
+	}
 
 	if err != nil {
 		missingPlugin := &missingPluginView{
 			Name:    name,
 			AppName: g.Name,
-			Details: err.Error(),
+			Details: g.redact(err.Error()),
 		}
 
-		if err := missingPlugin.render(g.Stdout); err != nil {
+		if err := missingPlugin.render(g.template("missingPlugin"), g.Stdout); err != nil {
 			return fmt.Errorf(
 				"Unable to render missing plugin error: %+v - %s",
 				missingPlugin,
@@ -166,16 +617,98 @@ func (g *GatewayT) runPlugin(name string, args []string) error {
 			)
 		}
 
-		return g.helpAction(name, args)
+		if err := g.helpAction("help", nil); err != nil {
+			return err
+		}
+
+		// The error is shown above help already, it only makes the gateway
+		// exit with non-zero status, and workflows stop at the step.
+		return renderedError{fmt.Errorf("Unable to run '%s' - %s", name, missingPlugin.Details)}
 	}
 
 	return nil
 }
 
+// showUsage reports wrong arguments of a built-in command. The error it
+// returns makes the gateway exit with non-zero status.
+func (g *GatewayT) showUsage(usage string) error {
+	if g.output == outputJSON {
+		if err := g.renderError(&errorT{
+			Message: "Wrong arguments",
+			Usage:   g.Name + " " + usage,
+		}); err != nil {
+			return err
+		}
+	} else {
+		view := &usageErrorView{
+			AppName: g.Name,
+			Usage:   usage,
+		}
+
+		if err := view.render(g.template("usageError"), g.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return renderedError{fmt.Errorf("Wrong arguments, usage: %s %s", g.Name, usage)}
+}
+
+func (g *GatewayT) stderr() io.Writer {
+	if g.Stderr == nil {
+		return ioutil.Discard
+	}
+
+	return g.Stderr
+}
+
+// groupCommands puts commands into groups by category. Commands without a
+// category come first, followed by categories in alphabetical order.
+func groupCommands(commands []*commandT) []*commandGroupT {
+	groups := map[string]*commandGroupT{}
+	names := []string{}
+
+	for _, c := range commands {
+		if _, ok := groups[c.Category]; !ok {
+			groups[c.Category] = &commandGroupT{Name: c.Category}
+			names = append(names, c.Category)
+		}
+
+		groups[c.Category].Commands = append(groups[c.Category].Commands, c)
+	}
+
+	sort.Strings(names)
+
+	result := []*commandGroupT{}
+	for _, name := range names {
+		result = append(result, groups[name])
+	}
+
+	return result
+}
+
+func resolvePlugin(tx Tx, name string) (*pluginT, error) {
+	b := tx.Bucket("plugins")
+	if b == nil {
+		return nil, fmt.Errorf("There are no plugins installed")
+	}
+
+	if aliases := tx.Bucket("aliases"); aliases != nil {
+		if target := aliases.Get([]byte(name)); target != nil {
+			name = string(target)
+		}
+	}
+
+	return pluginFrom(b, name)
+}
+
 func argsToAction(args []string) (string, []string) {
 	if len(args) == 1 || args[1] == "--help" {
 		return "help", []string{}
 	}
 
+	if isGatewayFlag(args[1]) {
+		return "help", args[1:]
+	}
+
 	return args[1], args[2:]
 }
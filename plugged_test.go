@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -18,9 +20,16 @@ func TestGateway(t *testing.T) {
 		description string
 		home        string
 		path        string
+		workdir     string
 		files       map[string]string
+		registry    map[string]map[string]string
+		templates   map[string]string
+		categories  map[string]string
+		stdin       string
 		scenario    [][]string
 		output      string
+		errors      []string
+		generated   map[string]string
 	}{
 
 		"default message without any plugins": {
@@ -241,18 +250,745 @@ func TestGateway(t *testing.T) {
 				{"exampleapp", "find", "stuff"},
 			},
 
+			errors: []string{"Unable to run 'find' - Plugin 'find' was not found"},
+
+			output: dedent(`
+                              |[ERROR] Unable to find plugin 'find'.
+                              |Try installing it with 'exampleapp --plugged-install find'.
+                              |Details: Plugin 'find' was not found
+                              |
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- activate\t - Activate stuff.
+                              |- help\t\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"find command when find plugin is not installed": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+			files:       map[string]string{},
+
+			scenario: [][]string{
+				{"exampleapp", "find", "stuff"},
+			},
+
+			errors: []string{"Unable to run 'find' - There are no plugins installed"},
+
+			output: dedent(`
+                              |[ERROR] Unable to find plugin 'find'.
+                              |Try installing it with 'exampleapp --plugged-install find'.
+                              |Details: There are no plugins installed
+                              |
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- help\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"find command when find plugin is installed": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-activate": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Activate stuff."
+                              `),
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Activate stuff."
+                                      |else
+                                      |  echo "Found $1 and maybe($2)."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "activate", "find"},
+				{"exampleapp", "find", "stuff", "things"},
+			},
+
+			output: dedent(`
+                              |Found stuff and maybe(things).
+                      `),
+		},
+
+		"find command invoked by alias": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |else
+                                      |  echo "Found $1."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-alias", "f", "find"},
+				{"exampleapp", "f", "stuff"},
+			},
+
+			output: dedent(`
+                              |Found stuff.
+                      `),
+		},
+
+		"export and import of plugin registry": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Version": "1.2.0"}'
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-alias", "f", "find"},
+				{"exampleapp", "--plugged-export", "./tmp/home/plugins.lock"},
+				{"exampleapp", "--plugged-import", "./tmp/home/plugins.lock"},
+			},
+
+			output: dedent(`
+                              |find: up to date
+                              |f: alias for find
+                      `),
+		},
+
+		"plugins required by project manifest are missing": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+			workdir:     "./tmp/home/project/src",
+
+			files: map[string]string{
+				"./tmp/home/project/.exampleapp-plugins.json": `{"Plugins": {"find": ">=1.2", "deploy": ""}}`,
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Version": "1.1.0"}'
+                                      |else
+                                      |  echo "Found $1."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "find", "stuff"},
+			},
+
+			output: dedent(`
+                              |[WARNING] Plugins required by ../.exampleapp-plugins.json are not installed properly:
+                              |- deploy: not installed
+                              |- find (>=1.2): version '1.1.0' does not satisfy '>=1.2'
+                              |Run 'exampleapp --plugged-sync' to install them.
+                              |
+                              |Found stuff.
+                      `),
+		},
+
+		"sync plugins required by project manifest": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+			workdir:     "./tmp/home/project",
+
+			files: map[string]string{
+				"./tmp/home/project/.exampleapp-plugins.json": `{"Plugins": {"find": "^1.2"}}`,
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Version": "1.3.0"}'
+                                      |else
+                                      |  echo "Found $1."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-sync"},
+				{"exampleapp", "find", "stuff"},
+			},
+
+			output: dedent(`
+                              |find: Installed version '1.3.0'
+                              |Found stuff.
+                      `),
+		},
+
+		"shell runs commands until exit": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |arg=$1
+                                      |if test "$arg" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |else
+                                      |  echo "finding $*"
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-fail": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |arg=$1
+                                      |if test "$arg" = "--plugged-description"; then
+                                      |  echo -n "Always fails."
+                                      |else
+                                      |  exit 3
+                                      |fi
+                              `),
+			},
+
+			stdin: dedent(`
+                              |f?
+                              |find "two words"
+                              |fail
+                              |!1
+                              |history
+                              |exit
+                              |find never
+                      `),
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find", "fail"},
+				{"exampleapp", "--plugged-shell"},
+			},
+
+			output: dedent(`
+                              |exampleapp> fail  find
+                              |exampleapp> finding two words
+                              |exampleapp> [ERROR] Plugin 'fail' exited with status 3
+                              |exampleapp> find "two words"
+                              |finding two words
+                              |exampleapp>    1  find "two words"
+                              |   2  fail
+                              |   3  find "two words"
+                              |   4  history
+                      `) + "exampleapp> ",
+		},
+
+		"shell hides sensitive arguments in history file": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |arg=$1
+                                      |if test "$arg" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |else
+                                      |  echo "finding $*"
+                                      |fi
+                              `),
+			},
+
+			registry: map[string]map[string]string{
+				"aliases": {
+					"f": "find",
+				},
+			},
+
+			stdin: dedent(`
+                              |f --token abc "two words"
+                              |!!
+                              |find 'one word'
+                              |exit
+                      `),
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-shell"},
+			},
+
+			output: dedent(`
+                              |exampleapp> finding --token abc two words
+                              |exampleapp> f --token abc "two words"
+                              |finding --token abc two words
+                              |exampleapp> finding one word
+                      `) + "exampleapp> ",
+
+			generated: map[string]string{
+				"./tmp/home/.exampleapp_history": dedent(`
+                                      |f --token [REDACTED] "two words"
+                                      |f --token [REDACTED] "two words"
+                                      |find 'one word'
+                              `),
+			},
+		},
+
+		"shell completes arguments of plugins serving rpc": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |case "$1" in
+                                      |  --plugged-description) echo -n "Find some stuff." ;;
+                                      |  --plugged-metadata) echo '{"Serve": true}' ;;
+                                      |  --plugged-serve)
+                                      |    calls=0
+                                      |    while read -r request; do
+                                      |      calls=$((calls + 1))
+                                      |      id=$(echo "$request" | sed 's/.*"id":\([0-9]*\).*/\1/')
+                                      |      echo "{\"id\":$id,\"result\":[\"alpha\",\"beta\",\"call$calls\"],\"error\":null}"
+                                      |    done ;;
+                                      |esac
+                              `),
+			},
+
+			stdin: dedent(`
+                              |find --deep a?
+                              |find ?
+                              |exit
+                      `),
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-shell"},
+			},
+
+			output: dedent(`
+                              |exampleapp> alpha
+                              |exampleapp> alpha  beta  call2
+                      `) + "exampleapp> ",
+		},
+
+		"script plugins run through interpreter": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-greet.sh": dedent(`
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Greet someone."
+                                      |else
+                                      |  echo "Hello, $1!"
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "greet"},
+				{"exampleapp", "greet", "world"},
+				{"exampleapp", "--plugged-search"},
+			},
+
+			output: dedent(`
+                              |Hello, world!
+                              |* greet\t - Greet someone.
+                      `),
+		},
+
+		"uninstall plugins": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Find some stuff."
+                              `),
+				"./tmp/bin/exampleapp-activate": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Activate stuff."
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find", "activate"},
+				{"exampleapp", "--plugged-alias", "f", "find"},
+				{"exampleapp", "--plugged-uninstall", "find", "deploy"},
+				{"exampleapp", "f"},
+			},
+
+			errors: []string{"Unable to run 'f' - Plugin 'f' was not found"},
+
+			output: dedent(`
+                              |find: Uninstalled
+                              |deploy: Failed to uninstall - Plugin is not installed
+                              |[ERROR] Unable to find plugin 'f'.
+                              |Try installing it with 'exampleapp --plugged-install f'.
+                              |Details: Plugin 'f' was not found
+                              |
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- activate\t - Activate stuff.
+                              |- help\t\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"install plugins with dependencies": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-deploy": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Version": "1.0.0", "Depends": {"build": "^1.0", "lint": "*"}}'
+                                      |else
+                                      |  echo -n "Deploy stuff."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-build": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Version": "1.2.0"}'
+                                      |else
+                                      |  echo -n "Build stuff."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-lint": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Version": "0.3.0"}'
+                                      |else
+                                      |  echo -n "Lint stuff."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "deploy"},
+				{"exampleapp", "--plugged-uninstall", "build"},
+				{"exampleapp", "--plugged-uninstall", "build", "deploy"},
+				{"exampleapp"},
+			},
+
+			output: dedent(`
+                              |build: Installed as a dependency of deploy
+                              |lint: Installed as a dependency of deploy
+                              |build: Failed to uninstall - Plugin is required by deploy
+                              |build: Uninstalled
+                              |deploy: Uninstalled
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- lint\t - Lint stuff.
+                              |- help\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"install plugins with unmet dependencies": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-ping": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Depends": {"pong": ""}}'
+                                      |else
+                                      |  echo -n "Ping."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-pong": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Depends": {"ping": ""}}'
+                                      |else
+                                      |  echo -n "Pong."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-publish": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Depends": {"build": ">=2.0"}}'
+                                      |else
+                                      |  echo -n "Publish stuff."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-build": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Version": "1.2.0"}'
+                                      |else
+                                      |  echo -n "Build stuff."
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-release": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"Depends": {"missing": ""}}'
+                                      |else
+                                      |  echo -n "Release stuff."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "ping", "publish", "release"},
+			},
+
+			output: dedent(`
+                              |ping: Failed to install - Dependency cycle ping -> pong -> ping
+                              |publish: Failed to install - Dependency 'build' version '1.2.0' does not satisfy '>=2.0'
+                              |release: Failed to install - Unable to install dependency 'missing' - Unable to find binary for plugin 'missing' - exec: "exampleapp-missing": executable file not found in $PATH
+                              |pong: Failed to install - Dependency cycle pong -> ping -> pong
+                              |build: Failed to install - Not needed, as 'publish' failed to install
+                              |missing: Failed to get metadata - Unable to find binary for plugin 'missing' - exec: "exampleapp-missing": executable file not found in $PATH
+                      `),
+		},
+
+		"workflows stop at plugins that can not be run": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-build": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Build stuff."
+                                      |  exit
+                                      |fi
+                                      |echo "Building."
+                              `),
+			},
+
+			registry: map[string]map[string]string{
+				"plugins": {
+					"lint": `{"Name":"lint","Description":"Lint stuff.","AppName":"exampleapp"}`,
+				},
+				"workflows": {
+					"release": "lint && build",
+				},
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "build"},
+				{"exampleapp", "release"},
+			},
+
+			errors: []string{"Workflow 'release' failed at 1 step(s)"},
+
+			output: dedent(`
+                              |==> [1/2] lint
+                              |[ERROR] Unable to find plugin 'lint'.
+                              |Try installing it with 'exampleapp --plugged-install lint'.
+                              |Details: Unable to find binary for plugin 'lint' - exec: "exampleapp-lint": executable file not found in $PATH
+                              |
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- build\t\t - Build stuff.
+                              |- lint\t\t - Lint stuff.
+                              |- release\t - Runs lint && build
+                              |- help\t\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                              |
+                              |Workflow 'release':
+                              |lint   failed  Unable to run 'lint' - Unable to find binary for plugin 'lint' - exec: "exampleapp-lint": executable file not found in $PATH
+                              |build  skipped
+                      `),
+		},
+
+		"workflows hide sensitive arguments": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-deploy": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Deploy stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo -n '{"SensitiveFlags": ["--key"]}'
+                                      |else
+                                      |  echo "Deploying."
+                                      |fi
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "deploy"},
+				{"exampleapp", "--plugged-workflow", "release", "deploy --key abc --token=xyz prod"},
+				{"exampleapp", "--plugged-workflow"},
+				{"exampleapp", "release"},
+				{"exampleapp"},
+			},
+
+			output: dedent(`
+                              |release = deploy --key [REDACTED] --token=[REDACTED] prod
+                              |==> [1/1] deploy --key [REDACTED] --token=[REDACTED] prod
+                              |Deploying.
+                              |
+                              |Workflow 'release':
+                              |deploy --key [REDACTED] --token=[REDACTED] prod  ok
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- deploy\t - Deploy stuff.
+                              |- release\t - Runs deploy --key [REDACTED] --token=[REDACTED] prod
+                              |- help\t\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"workflows chain plugins": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-lint": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Lint stuff."
+                                      |  exit
+                                      |fi
+                                      |echo "Linting."
+                              `),
+				"./tmp/bin/exampleapp-test": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Test stuff."
+                                      |  exit
+                                      |fi
+                                      |echo "Testing."
+                                      |exit 1
+                              `),
+				"./tmp/bin/exampleapp-build": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Build stuff."
+                                      |  exit
+                                      |fi
+                                      |echo "Building $*."
+                              `),
+				"./tmp/bin/exampleapp-publish": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Publish stuff."
+                                      |  exit
+                                      |fi
+                                      |echo "Publishing."
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "lint", "test", "build", "publish"},
+				{"exampleapp", "--plugged-workflow", "release", "lint && test && build --prod ; publish"},
+				{"exampleapp", "--plugged-workflow"},
+				{"exampleapp", "release"},
+				{"exampleapp"},
+			},
+
+			errors: []string{"Workflow 'release' failed at 1 step(s)"},
+
 			output: dedent(`
-                              |[ERROR] Unable to find plugin 'find'.
-                              |Try installing it with 'exampleapp --plugged-install find'.
-                              |Details: Plugin 'find' was not found
+                              |release = lint && test && build --prod ; publish
+                              |==> [1/4] lint
+                              |Linting.
+                              |==> [2/4] test
+                              |Testing.
+                              |==> [4/4] publish
+                              |Publishing.
                               |
+                              |Workflow 'release':
+                              |lint          ok
+                              |test          failed  Plugin 'test' exited with status 1
+                              |build --prod  skipped
+                              |publish       ok
                               |USAGE: exampleapp command [options]
                               |
                               |exampleapp - An example CLI application.
                               |
                               |Available commands:
                               |
-                              |- activate\t - Activate stuff.
+                              |- build\t\t - Build stuff.
+                              |- lint\t\t - Lint stuff.
+                              |- publish\t - Publish stuff.
+                              |- test\t\t - Test stuff.
+                              |- release\t - Runs lint && test && build --prod ; publish
                               |- help\t\t - This info.
                               |
                               |To get help for any of commands you can do 'exampleapp help command'
@@ -260,28 +996,77 @@ func TestGateway(t *testing.T) {
                       `),
 		},
 
-		"find command when find plugin is not installed": {
+		"stats of plugin invocations": {
 			name:        "exampleapp",
 			description: "An example CLI application.",
 			home:        "./tmp/home",
 			path:        "./tmp/bin",
-			files:       map[string]string{},
+
+			registry: map[string]map[string]string{
+				"invocations": {
+					"20260101T100000.000000000Z find":   `{"Time":"2026-01-01T10:00:00Z","Plugin":"find","Args":["stuff"]}`,
+					"20260101T110000.000000000Z deploy": `{"Time":"2026-01-01T11:00:00Z","Plugin":"deploy","Args":["--token","[REDACTED]"],"ExitCode":0,"Duration":2500000000}`,
+					"20260101T120000.000000000Z find":   `{"Time":"2026-01-01T12:00:00Z","Plugin":"find","Args":["more"],"ExitCode":1,"Duration":100000000}`,
+					"20260101T130000.000000000Z find":   `{"Time":"2026-01-01T13:00:00Z","Plugin":"find","Args":[],"ExitCode":0,"Duration":300000000}`,
+				},
+			},
 
 			scenario: [][]string{
-				{"exampleapp", "find", "stuff"},
+				{"exampleapp", "--plugged-stats"},
 			},
 
 			output: dedent(`
-                              |[ERROR] Unable to find plugin 'find'.
-                              |Try installing it with 'exampleapp --plugged-install find'.
-                              |Details: There are no plugins installed
+                              |PLUGIN  RUNS  FAILED  AVG TIME  MAX TIME
+                              |find    3     1/2     200ms     300ms
+                              |deploy  1     0/1     2.5s      2.5s
                               |
+                              |Slowest invocations:
+                              |2.5s   deploy --token [REDACTED]
+                              |300ms  find
+                              |100ms  find more
+                      `),
+		},
+
+		"doctor repairs corrupted registry entries": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |fi
+                              `),
+			},
+
+			registry: map[string]map[string]string{
+				"plugins": {
+					"find": "{broken",
+					"gone": "{broken",
+				},
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-doctor", "--repair"},
+				{"exampleapp"},
+			},
+
+			output: dedent(`
+                              |store: ok
+                              |registry: ok - schema version 1
+                              |find: repaired - reinstalled from tmp/bin/exampleapp-find
+                              |gone: removed - unable to reinstall, entry was removed
+                              |find: ok - tmp/bin/exampleapp-find
                               |USAGE: exampleapp command [options]
                               |
                               |exampleapp - An example CLI application.
                               |
                               |Available commands:
                               |
+                              |- find\t - Find some stuff.
                               |- help\t - This info.
                               |
                               |To get help for any of commands you can do 'exampleapp help command'
@@ -289,35 +1074,359 @@ func TestGateway(t *testing.T) {
                       `),
 		},
 
-		"find command when find plugin is installed": {
+		"doctor reports broken plugins": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Find some stuff."
+                              `),
+			},
+
+			registry: map[string]map[string]string{
+				"plugins": {
+					"activate": `{"Name":"activate","Description":"Activate stuff.","AppName":"exampleapp"}`,
+				},
+				"aliases": {
+					"f": "find",
+					"a": "deploy",
+				},
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-doctor", "--output", "json"},
+			},
+
+			output: dedent(`
+                              |{
+                              |  "Checks": [
+                              |    {
+                              |      "Subject": "store",
+                              |      "Status": "ok"
+                              |    },
+                              |    {
+                              |      "Subject": "registry",
+                              |      "Status": "ok",
+                              |      "Details": "schema version 1"
+                              |    },
+                              |    {
+                              |      "Subject": "activate",
+                              |      "Status": "problem",
+                              |      "Details": "'exampleapp-activate' can not be found on PATH"
+                              |    },
+                              |    {
+                              |      "Subject": "find",
+                              |      "Status": "ok",
+                              |      "Details": "tmp/bin/exampleapp-find"
+                              |    },
+                              |    {
+                              |      "Subject": "a",
+                              |      "Status": "problem",
+                              |      "Details": "alias for 'deploy', which is not installed"
+                              |    }
+                              |  ],
+                              |  "Problems": 2
+                              |}
+                      `),
+
+			errors: []string{"Found 2 problem(s) with exampleapp installation"},
+		},
+
+		"help and install in json mode": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Find some stuff."
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "--output=json", "missing"},
+				{"exampleapp", "--output", "json"},
+				{"exampleapp", "--plugged-alias", "--output", "json"},
+			},
+
+			errors: []string{"Wrong arguments, usage: exampleapp --plugged-alias alias plugin"},
+
+			output: dedent(`
+                              |{
+                              |  "Installed": [],
+                              |  "Failed": {
+                              |    "missing": "Unable to find binary for plugin 'missing' - exec: \"exampleapp-missing\": executable file not found in $PATH"
+                              |  }
+                              |}
+                              |{
+                              |  "Name": "exampleapp",
+                              |  "Description": "An example CLI application.",
+                              |  "Commands": [
+                              |    {
+                              |      "Name": "help",
+                              |      "Description": "This info.",
+                              |      "Builtin": true
+                              |    }
+                              |  ]
+                              |}
+                              |{
+                              |  "Error": {
+                              |    "Message": "Wrong arguments",
+                              |    "Usage": "exampleapp --plugged-alias alias plugin"
+                              |  }
+                              |}
+                      `),
+		},
+
+		"list and search plugins": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Version": "1.2.0"}'
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-activate": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Activate stuff."
+                              `),
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-list"},
+				{"exampleapp", "--plugged-search"},
+				{"exampleapp", "--plugged-search", "activ"},
+			},
+
+			output: dedent(`
+                              |find 1.2.0 tmp/bin/exampleapp-find
+                              |  activate\t - Activate stuff.
+                              |* find\t\t - Find some stuff.
+                              |  activate\t - Activate stuff.
+                      `),
+		},
+
+		"customized templates": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |echo -n "Find some stuff in all the places you can think of."
+                              `),
+			},
+
+			templates: map[string]string{
+				"missingPlugin": "Oops, there is no '{{.Name}}' yet.\n",
+				"gatewayHelp":   "{{.Name}}:\n{{.AvailableCommands}}\n",
+				"commandList":   "{{range group \"AppName\" .PluginList}}{{range .Plugins}}{{.Name}}:\n{{wrap 20 .Description}}\n{{end}}{{end}}",
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "activate"},
+			},
+
+			errors: []string{"Unable to run 'activate' - Plugin 'activate' was not found"},
+
+			output: dedent(`
+                              |Oops, there is no 'activate' yet.
+                              |exampleapp:
+                              |find:
+                              |Find some stuff in
+                              |all the places you
+                              |can think of.
+                              |
+                      `),
+		},
+
+		"help groups commands by category": {
 			name:        "exampleapp",
 			description: "An example CLI application.",
 			home:        "./tmp/home",
 			path:        "./tmp/bin",
 
 			files: map[string]string{
+				"./tmp/bin/exampleapp-find": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Category": "Data"}'
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-deploy": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Deploy stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Category": "Deploy"}'
+                                      |fi
+                              `),
+				"./tmp/bin/exampleapp-debug": dedent(`
+                                      |#!/usr/bin/env sh
+                                      |if test "$1" = "--plugged-description"; then
+                                      |  echo -n "Internal debugging."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Category": "Data", "Hidden": true}'
+                                      |fi
+                              `),
 				"./tmp/bin/exampleapp-activate": dedent(`
                                       |#!/usr/bin/env sh
                                       |echo -n "Activate stuff."
                               `),
+			},
+
+			categories: map[string]string{
+				"help": "Other",
+			},
+
+			scenario: [][]string{
+				{"exampleapp", "--plugged-install", "find", "deploy", "debug", "activate"},
+				{"exampleapp"},
+				{"exampleapp", "help", "--all"},
+			},
+
+			output: dedent(`
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- activate\t - Activate stuff.
+                              |
+                              |Data:
+                              |- find\t - Find some stuff.
+                              |
+                              |Deploy:
+                              |- deploy - Deploy stuff.
+                              |
+                              |Other:
+                              |- help\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                              |USAGE: exampleapp command [options]
+                              |
+                              |exampleapp - An example CLI application.
+                              |
+                              |Available commands:
+                              |
+                              |- activate\t - Activate stuff.
+                              |
+                              |Data:
+                              |- debug\t - Internal debugging.
+                              |- find\t - Find some stuff.
+                              |
+                              |Deploy:
+                              |- deploy - Deploy stuff.
+                              |
+                              |Other:
+                              |- help\t - This info.
+                              |
+                              |To get help for any of commands you can do 'exampleapp help command'
+                              |or 'exampleapp command --help'.
+                      `),
+		},
+
+		"reference docs generation": {
+			name:        "exampleapp",
+			description: "An example CLI application.",
+			home:        "./tmp/home",
+			path:        "./tmp/bin",
+
+			files: map[string]string{
 				"./tmp/bin/exampleapp-find": dedent(`
                                       |#!/usr/bin/env sh
                                       |if test "$1" = "--plugged-description"; then
-                                      |  echo -n "Activate stuff."
-                                      |else
-                                      |  echo "Found $1 and maybe($2)."
+                                      |  echo -n "Find some stuff."
+                                      |elif test "$1" = "--plugged-metadata"; then
+                                      |  echo '{"Version": "1.2.0", "Category": "Data"}'
+                                      |elif test "$1" = "--help"; then
+                                      |  echo "USAGE: exampleapp find [query]"
+                                      |  echo ".. and some more details."
                                       |fi
                               `),
 			},
 
 			scenario: [][]string{
-				{"exampleapp", "--plugged-install", "activate", "find"},
-				{"exampleapp", "find", "stuff", "things"},
+				{"exampleapp", "--plugged-install", "find"},
+				{"exampleapp", "--plugged-docs", "--out", "./tmp/home/docs"},
+				{"exampleapp", "--plugged-docs", "--format", "man", "--out", "./tmp/home/man"},
 			},
 
 			output: dedent(`
-                              |Found stuff and maybe(things).
+                              |Wrote tmp/home/docs/exampleapp.md
+                              |Wrote tmp/home/docs/exampleapp-find.md
+                              |Wrote tmp/home/man/exampleapp.1
+                              |Wrote tmp/home/man/exampleapp-find.1
                       `),
+
+			generated: map[string]string{
+				"./tmp/home/docs/exampleapp.md": dedent(`
+                                      |# exampleapp
+                                      |
+                                      |An example CLI application.
+                                      |
+                                      |## Usage
+                                      |
+                                      |    exampleapp command [options]
+                                      |
+                                      |## Commands
+                                      |
+                                      |### Data
+                                      |
+                                      |- [find](exampleapp-find.md) - Find some stuff.
+                              `),
+				"./tmp/home/docs/exampleapp-find.md": dedent(`
+                                      |# exampleapp find
+                                      |
+                                      |Find some stuff.
+                                      |
+                                      |Version: 1.2.0
+                                      |
+                                      |## Usage
+                                      |
+                                      |    USAGE: exampleapp find [query]
+                                      |    .. and some more details.
+                                      |
+                                      |See also [exampleapp](exampleapp.md).
+                              `),
+				"./tmp/home/man/exampleapp-find.1": dedent(`
+                                      |.TH "exampleapp-find" 1 "" "find 1.2.0"
+                                      |.SH NAME
+                                      |exampleapp-find \- Find some stuff.
+                                      |.SH SYNOPSIS
+                                      |.B exampleapp find
+                                      |[arguments]
+                                      |.SH DESCRIPTION
+                                      |.nf
+                                      |USAGE: exampleapp find [query]
+                                      |\&.. and some more details.
+                                      |.fi
+                                      |.SH SEE ALSO
+                                      |.BR exampleapp (1)
+                              `),
+			},
 		},
 	}
 
@@ -340,6 +1449,10 @@ func TestGateway(t *testing.T) {
 			defer os.Setenv("PATH", oldPath)
 
 			for path, contents := range example.files {
+				if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+					t.Fatalf("Unable to create directory for %s - %s", path, err)
+				}
+
 				if err := ioutil.WriteFile(path, []byte(contents), 0777); err != nil {
 					t.Fatalf("Unable to create file %s - %s", path, err)
 				}
@@ -347,12 +1460,17 @@ func TestGateway(t *testing.T) {
 
 			stdout := &bytes.Buffer{}
 			gateway := &GatewayT{
-				Stdin:       bytes.NewBufferString(""),
+				Stdin:       bytes.NewBufferString(example.stdin),
 				Stdout:      stdout,
+				Stderr:      stdout,
 				Home:        example.home,
 				Name:        example.name,
 				Description: example.description,
 				ExecFn:      dumbExec(stdout),
+				WorkDir:     example.workdir,
+				Templates:   example.templates,
+
+				BuiltinCategories: example.categories,
 			}
 
 			if err := gateway.Connect(); err != nil {
@@ -361,9 +1479,51 @@ func TestGateway(t *testing.T) {
 			defer gateway.Disconnect()
 			defer os.Remove(example.home + "." + example.name + ".db")
 
+			err := gateway.Store.Update(func(tx Tx) error {
+				for name, items := range example.registry {
+					b, err := tx.CreateBucketIfNotExists(name)
+					if err != nil {
+						return err
+					}
+
+					for key, value := range items {
+						if err := b.Put([]byte(key), []byte(value)); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Unable to populate registry - %s", err)
+			}
+
+			var errors []string
 			for _, args := range example.scenario {
 				if err := gateway.Run(args); err != nil {
-					t.Fatal(err)
+					errors = append(errors, err.Error())
+				}
+			}
+
+			if !reflect.DeepEqual(errors, example.errors) {
+				t.Errorf("Expected errors %+v, got %+v", example.errors, errors)
+			}
+
+			for path, expected := range example.generated {
+				actual, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Errorf("Unable to read generated file %s - %s", path, err)
+					continue
+				}
+
+				if string(actual) != expected {
+					t.Errorf(
+						"\n=== Expected %s ===\n%s\n=== Actual %s ===\n%s\n=== END ===",
+						path,
+						expected,
+						path,
+						actual,
+					)
 				}
 			}
 
@@ -1,18 +1,59 @@
 package plugged
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-
-	"github.com/boltdb/bolt"
+	"path/filepath"
 )
 
 type pluginT struct {
 	Name        string `json:"Name"`
 	Description string `json:"Description"`
 	AppName     string `json:"AppName"`
+	Path        string `json:"Path,omitempty"`
+	Checksum    string `json:"Checksum,omitempty"`
+
+	// Approved are permissions user approved at installation, see
+	// GatewayT.Sandbox.
+	Approved *permissionsT `json:"Approved,omitempty"`
+
+	metadataT
+}
+
+// metadataT is what plugin may optionally report with --plugged-metadata.
+type metadataT struct {
+	Version string `json:"Version,omitempty"`
+
+	// Category groups plugin with others in help output.
+	Category string `json:"Category,omitempty"`
+
+	// Hidden plugins are shown in help output only with 'help --all'.
+	Hidden bool `json:"Hidden,omitempty"`
+
+	// SensitiveFlags, eg. "--token", have values that are redacted in
+	// output and audit log, and so are positional arguments at 1-based
+	// SensitiveArgs positions, counting arguments not starting with "-".
+	SensitiveFlags []string `json:"SensitiveFlags,omitempty"`
+	SensitiveArgs  []int    `json:"SensitiveArgs,omitempty"`
+
+	// Serve tells that plugin can be run with --plugged-serve to answer
+	// JSON-RPC calls, see Serve.
+	Serve bool `json:"Serve,omitempty"`
+
+	// Dirs are the only directories WebAssembly plugin can access.
+	Dirs []string `json:"Dirs,omitempty"`
+
+	// Depends are plugins this one calls, by name, with version
+	// constraints, eg. {"build": "^1.2"}. They are installed with it.
+	Depends map[string]string `json:"Depends,omitempty"`
+
+	// Permissions are what plugin needs when run sandboxed.
+	Permissions *permissionsT `json:"Permissions,omitempty"`
 }
 
 func newPlugin(appName, name string) *pluginT {
@@ -22,13 +63,17 @@ func newPlugin(appName, name string) *pluginT {
 	}
 }
 
-func listPlugins(store *bolt.Bucket) ([]*pluginT, error) {
+// listPlugins returns all plugins that could be decoded together with names
+// of the ones that could not.
+func listPlugins(store Bucket) ([]*pluginT, []string, error) {
 	plugins := []*pluginT{}
+	broken := []string{}
 
 	err := store.ForEach(func(key, data []byte) error {
 		plugin, err := decodePlugin(data, string(key))
 		if err != nil {
-			fmt.Printf("[ERROR] %s\n", err)
+			broken = append(broken, string(key))
+			return nil
 		}
 
 		plugins = append(plugins, plugin)
@@ -36,13 +81,13 @@ func listPlugins(store *bolt.Bucket) ([]*pluginT, error) {
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("Unable to fetch plugins from store - %s", err)
+		return nil, nil, fmt.Errorf("Unable to fetch plugins from store - %s", err)
 	}
 
-	return plugins, nil
+	return plugins, broken, nil
 }
 
-func pluginFrom(store *bolt.Bucket, name string) (*pluginT, error) {
+func pluginFrom(store Bucket, name string) (*pluginT, error) {
 	data := store.Get([]byte(name))
 	if data == nil {
 		return nil, fmt.Errorf("Plugin '%s' was not found", name)
@@ -61,24 +106,61 @@ func decodePlugin(data []byte, name string) (*pluginT, error) {
 	return plugin, nil
 }
 
-func (p *pluginT) install(g *GatewayT) error {
-	cmdName := p.AppName + "-" + p.Name
-	cmd := exec.Command(cmdName, "--plugged-description")
+func (p *pluginT) command() string {
+	return p.AppName + "-" + p.Name
+}
+
+func (p *pluginT) discover(g *GatewayT) error {
+	cmdName := p.command()
+
+	binary, err := g.findBinary(cmdName)
+	if err != nil {
+		return fmt.Errorf("Unable to find binary for plugin '%s' - %s", p.Name, err)
+	}
 
-	description, err := cmd.Output()
+	description, err := g.probe(p, binary, "--plugged-description")
 	if err != nil {
 		return fmt.Errorf("'%s --plugged-description' returned an error - %s", cmdName, err)
 	}
 
-	p.Description = string(description)
+	checksum, err := fileChecksum(binary)
+	if err != nil {
+		return err
+	}
 
-	if err := g.updatePlugin(p); err != nil {
-		return fmt.Errorf("Unable to save plugin to storage - %s", err)
+	p.Description = string(description)
+	p.Path = binary
+	p.Checksum = checksum
+	p.metadataT = metadataT{}
+
+	// Metadata is optional, so plugins that do not know about
+	// --plugged-metadata are still installed as usual.
+	if data, err := g.probe(p, binary, "--plugged-metadata"); err == nil {
+		metadata := metadataT{}
+		if err := json.Unmarshal(data, &metadata); err == nil {
+			p.metadataT = metadata
+		}
 	}
+
 	return nil
 }
 
-func (p *pluginT) save(store *bolt.Bucket) error {
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Unable to open %s to compute checksum - %s", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("Unable to read %s to compute checksum - %s", path, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *pluginT) save(store Bucket) error {
 	data, err := json.Marshal(p)
 	if err != nil {
 		return fmt.Errorf("Unable to marshal plugin %+v to json - %s", *p, err)
@@ -87,19 +169,84 @@ func (p *pluginT) save(store *bolt.Bucket) error {
 	return store.Put([]byte(p.Name), data)
 }
 
-func (p *pluginT) run(execFn func(string, []string, []string) error, args []string) error {
-	cmdName := p.AppName + "-" + p.Name
+func (p *pluginT) run(g *GatewayT, args []string) error {
+	cmdName := p.command()
 
-	binary, err := exec.LookPath(cmdName)
+	binary, err := g.findBinary(cmdName)
 	if err != nil {
 		return fmt.Errorf("Unable to find binary for plugin '%s' - %s", p.Name, err)
 	}
 
-	args = append([]string{cmdName}, args...)
+	execFn := g.ExecFn
+	if isWasm(binary) {
+		// Sandboxed plugins only access directories user approved.
+		dirs := p.Dirs
+		if g.Sandbox {
+			dirs = p.Approved.dirs()
+		}
+
+		execFn = func(binary string, argv, env []string) error {
+			return runWasm(g.context(), binary, argv, env, g.Stdin, g.Stdout, g.stderr(), dirs)
+		}
+	}
 
-	if err := execFn(binary, args, os.Environ()); err != nil {
-		return fmt.Errorf("Plugin '%s' failed to exec with args: %+v - %s", p.Name, args, err)
+	launch, err := g.launch(p, binary, args)
+	if err != nil {
+		return err
+	}
+
+	restore, err := launch.enter()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if err := execFn(launch.Binary, launch.Argv, launch.Env); err != nil {
+		// Exit status is reported by the name user knows the plugin by,
+		// not by the interpreter or sandbox helper running it.
+		if exitErr, ok := err.(*ExitError); ok {
+			return &ExitError{Name: p.Name, Code: exitErr.Code}
+		}
+
+		shown := append([]string{cmdName}, p.redactArgs(args)...)
+		return fmt.Errorf("Plugin '%s' failed to exec with args: %+v - %s", p.Name, shown, err)
 	}
 
 	return nil
 }
+
+// launch prepares run of plugin binary with args: through its interpreter,
+// with environment filtered by environ, and sandboxed when the gateway runs
+// plugins sandboxed. Every way of running plugins goes through it.
+func (g *GatewayT) launch(p *pluginT, binary string, args []string) (*launchT, error) {
+	var err error
+
+	// Sandboxed plugins run in another working directory.
+	if g.Sandbox {
+		if binary, err = filepath.Abs(binary); err != nil {
+			return nil, fmt.Errorf("Unable to find binary for plugin '%s' - %s", p.Name, err)
+		}
+	}
+
+	launch := &launchT{Binary: binary, Argv: append([]string{p.command()}, args...)}
+
+	if interpreter := g.interpreter(binary); interpreter != nil {
+		if launch.Binary, err = exec.LookPath(interpreter[0]); err != nil {
+			return nil, fmt.Errorf("Unable to find interpreter for plugin '%s' - %s", p.Name, err)
+		}
+
+		launch.Argv = append(append(append([]string{}, interpreter...), binary), args...)
+	}
+
+	if launch.Env, err = g.environ(p); err != nil {
+		return nil, err
+	}
+
+	if g.Sandbox {
+		if err := g.sandboxed(p, launch); err != nil {
+			return nil, err
+		}
+	}
+
+	return launch, nil
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package plugged
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup runs cmd in a process group of its own, and kills the
+// whole group once the context of cmd is done, so that processes plugin
+// started do not outlive it.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
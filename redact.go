@@ -0,0 +1,121 @@
+package plugged
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveName matches names of flags and variables, which values should
+// not be shown or recorded, eg. "--password" or "API_TOKEN".
+var sensitiveName = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[-_]?key|credential)`)
+
+// redactArgs hides values of sensitive arguments: of flags with sensitive
+// names and flags listed in plugin's SensitiveFlags, both "--flag value" and
+// "--flag=value", of sensitive "NAME=value" arguments, and positional
+// arguments listed in SensitiveArgs.
+func (p *pluginT) redactArgs(args []string) []string {
+	result := make([]string, len(args))
+	redactNext := false
+	position := 0
+
+	for i, arg := range args {
+		result[i] = arg
+
+		if redactNext {
+			result[i] = redacted
+			redactNext = false
+			continue
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			position++
+			if containsInt(p.SensitiveArgs, position) {
+				result[i] = redacted
+				continue
+			}
+		}
+
+		if eq := strings.Index(arg, "="); eq > 0 {
+			if p.isSensitiveFlag(arg[:eq]) || sensitiveName.MatchString(arg[:eq]) {
+				result[i] = arg[:eq+1] + redacted
+			}
+			continue
+		}
+
+		if p.isSensitiveFlag(arg) || strings.HasPrefix(arg, "-") && sensitiveName.MatchString(arg) {
+			redactNext = true
+		}
+	}
+
+	return result
+}
+
+func (p *pluginT) isSensitiveFlag(arg string) bool {
+	for _, flag := range p.SensitiveFlags {
+		if arg == flag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactArgs hides sensitive arguments of plugin, as well as anything
+// matching GatewayT.RedactPatterns.
+func (g *GatewayT) redactArgs(p *pluginT, args []string) []string {
+	result := p.redactArgs(args)
+	for i, arg := range result {
+		result[i] = g.redact(arg)
+	}
+
+	return result
+}
+
+// redact masks parts of text matching GatewayT.RedactPatterns. When pattern
+// has a group, only the first group is masked, eg. `token=(\S+)`.
+func (g *GatewayT) redact(text string) string {
+	for _, pattern := range g.RedactPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			groups := pattern.FindStringSubmatchIndex(match)
+			if len(groups) < 4 || groups[2] < 0 {
+				return redacted
+			}
+
+			return match[:groups[2]] + redacted + match[groups[3]:]
+		})
+	}
+
+	return text
+}
+
+// redactError masks sensitive parts of err message, keeping errors that need
+// special handling as they are.
+func (g *GatewayT) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := g.redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	if _, ok := err.(renderedError); ok {
+		return renderedError{errors.New(message)}
+	}
+
+	return errors.New(message)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
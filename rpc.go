@@ -0,0 +1,171 @@
+package plugged
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+)
+
+// rpcReceiverName is the name plugin's receiver is registered under by
+// Serve, so its methods are called as "Plugin.Method".
+const rpcReceiverName = "Plugin"
+
+// CompleteArgsT are arguments of "Plugin.Complete" method, that plugins
+// serving RPC can implement to complete their arguments in the shell. Args
+// are complete arguments typed so far, and Prefix is the one being typed.
+// The reply is a list of completions.
+type CompleteArgsT struct {
+	Args   []string
+	Prefix string
+}
+
+// Serve exposes exported methods of receiver to the gateway as JSON-RPC
+// over stdin and stdout, until the gateway closes stdin. Methods have to
+// follow net/rpc conventions, eg.:
+//
+//	func (p *FindPlugin) Complete(args *plugged.CompleteArgsT, reply *[]string) error
+//
+// Plugins should call Serve when run with --plugged-serve, and report
+// "Serve": true in their --plugged-metadata.
+func Serve(receiver interface{}) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcReceiverName, receiver); err != nil {
+		return fmt.Errorf("Unable to register RPC receiver - %s", err)
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(&stdioT{
+		Reader: os.Stdin,
+		Writer: os.Stdout,
+		Closer: os.Stdin,
+	}))
+
+	return nil
+}
+
+// stdioT joins input and output of a process into a single connection.
+type stdioT struct {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// processT closes the connection to a plugin process and waits for it to
+// exit. Plugin keeps running along the gateway, so its stderr is collected
+// and written to output once it exits, unless it is a file, which can be
+// written to concurrently.
+type processT struct {
+	stdin  io.Closer
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	output io.Writer
+}
+
+func (p *processT) Close() error {
+	p.stdin.Close()
+	err := p.cmd.Wait()
+
+	if p.stderr != nil {
+		p.output.Write(p.stderr.Bytes())
+	}
+
+	return err
+}
+
+// clientT is a client of plugin process, which is stopped once the context
+// it was dialed with is done.
+type clientT struct {
+	*rpc.Client
+	ctx context.Context
+}
+
+// Dial starts the named plugin with --plugged-serve, or returns the client
+// started before. Clients are closed, and plugin processes stopped, on
+// Disconnect.
+func (g *GatewayT) Dial(name string) (*rpc.Client, error) {
+	var plugin *pluginT
+	err := g.Store.View(func(tx Tx) error {
+		var err error
+		plugin, err = resolvePlugin(tx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g.dialPlugin(plugin)
+}
+
+func (g *GatewayT) dialPlugin(p *pluginT) (*rpc.Client, error) {
+	if client, ok := g.clients[p.Name]; ok {
+		if client.ctx.Err() == nil {
+			return client.Client, nil
+		}
+
+		client.Close()
+		delete(g.clients, p.Name)
+	}
+
+	if !p.Serve {
+		return nil, fmt.Errorf("Plugin '%s' does not support --plugged-serve", p.Name)
+	}
+
+	binary, err := g.findBinary(p.command())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to find binary for plugin '%s' - %s", p.Name, err)
+	}
+
+	ctx := g.context()
+	cmd, err := g.pluginCommand(ctx, p, binary, "--plugged-serve")
+	if err != nil {
+		return nil, err
+	}
+
+	process := &processT{cmd: cmd}
+	if f, ok := g.stderr().(*os.File); ok {
+		cmd.Stderr = f
+	} else {
+		process.stderr = &bytes.Buffer{}
+		process.output = g.stderr()
+		cmd.Stderr = process.stderr
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to plugin '%s' - %s", p.Name, err)
+	}
+	process.stdin = stdin
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to plugin '%s' - %s", p.Name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Unable to start plugin '%s' - %s", p.Name, err)
+	}
+
+	client := jsonrpc.NewClient(&stdioT{
+		Reader: stdout,
+		Writer: stdin,
+		Closer: process,
+	})
+
+	if g.clients == nil {
+		g.clients = map[string]*clientT{}
+	}
+	g.clients[p.Name] = &clientT{Client: client, ctx: ctx}
+
+	return client, nil
+}
+
+func (g *GatewayT) closeClients() {
+	for name, client := range g.clients {
+		client.Close()
+		delete(g.clients, name)
+	}
+}
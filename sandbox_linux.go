@@ -0,0 +1,44 @@
+package plugged
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// confine runs sandboxed plugin through SandboxHelper, bubblewrap, in its
+// own mount namespace where the file system is read-only except for the
+// private working directory and paths plugin can write to, and in its own
+// network namespace unless plugin can access the network.
+func (g *GatewayT) confine(s *launchT, permissions *permissionsT) error {
+	if g.SandboxHelper == "" {
+		return nil
+	}
+
+	helper, err := exec.LookPath(g.SandboxHelper)
+	if err != nil {
+		return fmt.Errorf("Unable to find sandbox helper - %s", err)
+	}
+
+	argv := []string{
+		g.SandboxHelper,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--bind", s.Dir, s.Dir,
+	}
+
+	for _, path := range permissions.writePaths(g.Home) {
+		argv = append(argv, "--bind-try", path, path)
+	}
+
+	if permissions == nil || !permissions.Network {
+		argv = append(argv, "--unshare-net")
+	}
+
+	argv = append(argv, "--unshare-ipc", "--unshare-pid", "--die-with-parent", "--chdir", s.Dir, "--", s.Binary)
+
+	s.Argv = append(argv, s.Argv[1:]...)
+	s.Binary = helper
+	return nil
+}
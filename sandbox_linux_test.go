@@ -0,0 +1,115 @@
+package plugged
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandbox(t *testing.T) {
+	if err := os.MkdirAll("./tmp/sandbox/bin", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/sandbox")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/sandbox/bin:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	os.Setenv("SANDBOX_ALLOWED", "allowed")
+	os.Setenv("SANDBOX_DENIED", "denied")
+	defer os.Unsetenv("SANDBOX_ALLOWED")
+	defer os.Unsetenv("SANDBOX_DENIED")
+
+	plugin := dedent(`
+          |#!/usr/bin/env sh
+          |if test "$1" = "--plugged-description"; then
+          |  echo -n "Inspect environment."
+          |elif test "$1" = "--plugged-metadata"; then
+          |  echo -n '{"Permissions": {"Env": ["SANDBOX_ALLOWED"]}}'
+          |else
+          |  pwd
+          |  echo "$SANDBOX_ALLOWED-$SANDBOX_DENIED"
+          |fi
+  `)
+	if err := ioutil.WriteFile("./tmp/sandbox/bin/exampleapp-env", []byte(plugin), 0777); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	g := &GatewayT{
+		Stdin:   bytes.NewBufferString("n\n"),
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Home:    "./tmp/sandbox/home",
+		Name:    "exampleapp",
+		Store:   NewMemoryStore(),
+		Sandbox: true,
+		ExecFn: func(binary string, argv, env []string) error {
+			cmd := exec.Command(binary, argv[1:]...)
+			cmd.Env = env
+			cmd.Stdout = stdout
+			return cmd.Run()
+		},
+	}
+
+	if err := g.Run([]string{"exampleapp", "--plugged-install", "env"}); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := "Plugin 'env' requests permission to:\n- read environment variables: SANDBOX_ALLOWED\nApprove? [y/N] "
+	if actual := stderr.String(); actual != prompt {
+		t.Errorf("\nExpected: %q\nActual:   %q", prompt, actual)
+	}
+
+	expected := "env: Failed to install - Permissions were not approved\n"
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+
+	g.Stdin = bytes.NewBufferString("y\n")
+	stdout.Reset()
+	stderr.Reset()
+
+	if err := g.Run([]string{"exampleapp", "--plugged-install", "env"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Run([]string{"exampleapp", "env"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _ := filepath.Abs("./tmp/sandbox/home/.exampleapp-sandbox/env")
+	if expected := dir + "\nallowed-\n"; stdout.String() != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, stdout.String())
+	}
+
+	// Plugins are probed for help in the sandbox as well.
+	if err := g.Run([]string{"exampleapp", "--plugged-docs", "--out", "./tmp/sandbox/docs"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := ioutil.ReadFile("./tmp/sandbox/docs/exampleapp-env.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := dir + "\n    allowed-"; !strings.Contains(string(page), expected) {
+		t.Errorf("Expected help captured in the sandbox %q, got:\n%s", expected, page)
+	}
+
+	// Approved permissions are not asked for again.
+	stderr.Reset()
+	if err := g.Run([]string{"exampleapp", "--plugged-install", "env"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no prompt, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package plugged
+
+import "fmt"
+
+// confine only supports SandboxHelper on Linux, elsewhere sandboxed plugins
+// get scrubbed environment and private working directory only.
+func (g *GatewayT) confine(s *launchT, permissions *permissionsT) error {
+	if g.SandboxHelper == "" {
+		return nil
+	}
+
+	return fmt.Errorf("Sandbox helper is only supported on Linux")
+}
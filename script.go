@@ -0,0 +1,127 @@
+package plugged
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// defaultInterpreters run script plugins by their extension, eg.
+// "appname-find.py", unless GatewayT.Interpreters say otherwise.
+var defaultInterpreters = map[string][]string{
+	".py": {"python3"},
+	".js": {"node"},
+	".rb": {"ruby"},
+	".sh": {"sh"},
+}
+
+// interpreters returns interpreter command lines by script extension.
+func (g *GatewayT) interpreters() map[string][]string {
+	interpreters := map[string][]string{}
+	for ext, command := range defaultInterpreters {
+		interpreters[ext] = command
+	}
+
+	for ext, command := range g.Interpreters {
+		interpreters[ext] = command
+	}
+
+	return interpreters
+}
+
+// interpreter returns command line to run script plugin with, or nil when
+// binary is not a script.
+func (g *GatewayT) interpreter(binary string) []string {
+	command := g.interpreters()[filepath.Ext(binary)]
+	if len(command) == 0 {
+		return nil
+	}
+
+	return command
+}
+
+// pluginExtensions are extensions plugin files can have besides none: of
+// WebAssembly modules and scripts.
+func (g *GatewayT) pluginExtensions() []string {
+	extensions := []string{wasmExtension}
+	for ext := range g.interpreters() {
+		extensions = append(extensions, ext)
+	}
+
+	sort.Strings(extensions[1:])
+	return extensions
+}
+
+// pluginDirs are directories plugins are looked up in: PluginDirs first,
+// then PATH.
+func (g *GatewayT) pluginDirs() []string {
+	dirs := append([]string{}, g.PluginDirs...)
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// findBinary looks for plugin command in PluginDirs, then on PATH. Within
+// each PluginDirs entry, and on PATH as a whole, executables take
+// precedence over WebAssembly modules and scripts named after it.
+func (g *GatewayT) findBinary(cmdName string) (string, error) {
+	for _, dir := range g.PluginDirs {
+		path := filepath.Join(dir, cmdName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+
+		if path := g.findScript(dir, cmdName); path != "" {
+			return path, nil
+		}
+	}
+
+	binary, err := exec.LookPath(cmdName)
+	if err == nil {
+		return binary, nil
+	}
+
+	for _, dir := range g.pluginDirs()[len(g.PluginDirs):] {
+		if path := g.findScript(dir, cmdName); path != "" {
+			return path, nil
+		}
+	}
+
+	return "", err
+}
+
+// findScript returns WebAssembly module or script for plugin command in
+// dir, or "" when there is none.
+func (g *GatewayT) findScript(dir, cmdName string) string {
+	for _, ext := range g.pluginExtensions() {
+		path := filepath.Join(dir, cmdName+ext)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// pluginCommand is exec.CommandContext running plugin binary with args as
+// a child process, see GatewayT.launch.
+func (g *GatewayT) pluginCommand(ctx context.Context, p *pluginT, binary string, args ...string) (*exec.Cmd, error) {
+	launch, err := g.launch(p, binary, args)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, launch.Binary)
+	cmd.Args = launch.Argv
+	cmd.Env = launch.Env
+	cmd.Dir = launch.Dir
+	return cmd, nil
+}
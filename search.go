@@ -0,0 +1,121 @@
+package plugged
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// foundT is a plugin binary found on PATH by --plugged-search.
+type foundT struct {
+	Name        string `json:"Name"`
+	Description string `json:"Description"`
+	Path        string `json:"Path"`
+	Installed   bool   `json:"Installed"`
+}
+
+// discoverPlugins finds all plugins named <app>-<name> in plugin dirs and
+// on PATH: executables, WebAssembly modules and scripts. When the same name
+// is present in multiple directories, the one that takes precedence is
+// returned.
+func (g *GatewayT) discoverPlugins() []*foundT {
+	prefix := g.Name + "-"
+	extensions := map[string]bool{}
+	for _, ext := range g.pluginExtensions() {
+		extensions[ext] = true
+	}
+
+	found := map[string]*foundT{}
+
+	for _, dir := range g.pluginDirs() {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), prefix) || entry.IsDir() {
+				continue
+			}
+
+			name := strings.TrimPrefix(entry.Name(), prefix)
+			if ext := filepath.Ext(name); extensions[ext] {
+				name = strings.TrimSuffix(name, ext)
+			} else if entry.Mode()&0111 == 0 {
+				continue
+			}
+
+			if _, ok := found[name]; !ok && name != "" {
+				found[name] = &foundT{
+					Name: name,
+					Path: filepath.Join(dir, entry.Name()),
+				}
+			}
+		}
+	}
+
+	result := []*foundT{}
+	for _, f := range found {
+		result = append(result, f)
+	}
+
+	sort.Sort(foundByName(result))
+	return result
+}
+
+type foundByName []*foundT
+
+func (f foundByName) Len() int           { return len(f) }
+func (f foundByName) Less(i, j int) bool { return f[i].Name < f[j].Name }
+func (f foundByName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+func (g *GatewayT) searchAction(_ string, args []string) error {
+	if len(args) > 1 {
+		return g.showUsage("--plugged-search [term]")
+	}
+
+	term := ""
+	if len(args) == 1 {
+		term = strings.ToLower(args[0])
+	}
+
+	plugins, err := g.Plugins()
+	if err != nil {
+		return err
+	}
+
+	installed := map[string]*pluginT{}
+	for _, p := range plugins {
+		installed[p.Name] = p
+	}
+
+	results := []*foundT{}
+	for _, f := range g.discoverPlugins() {
+		// Plugins not installed yet are probed with no permissions.
+		p, ok := installed[f.Name]
+		if !ok {
+			p = newPlugin(g.Name, f.Name)
+		}
+
+		if description, err := g.probe(p, f.Path, "--plugged-description"); err == nil {
+			f.Description = string(description)
+		}
+
+		if term != "" &&
+			!strings.Contains(strings.ToLower(f.Name), term) &&
+			!strings.Contains(strings.ToLower(f.Description), term) {
+			continue
+		}
+
+		f.Installed = ok
+		results = append(results, f)
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(results)
+	}
+
+	view := &searchResultView{Results: results}
+	return view.render(g.template("searchResult"), g.Stdout)
+}
@@ -0,0 +1,261 @@
+package plugged
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shellHistorySize is how many of the most recent shell commands are kept
+// in the history file.
+const shellHistorySize = 1000
+
+// The shell runs other commands, including built-in ones, so it can only be
+// registered after builtinHandlers are initialized.
+func init() {
+	builtinHandlers["--plugged-shell"] = actionHandler((*GatewayT).shellAction)
+}
+
+func (g *GatewayT) shellAction(action string, args []string) error {
+	if len(args) != 0 {
+		return g.showUsage(action)
+	}
+
+	// History keeps commands as typed for the session, and the history
+	// file gets them with sensitive arguments hidden.
+	history := g.loadHistory()
+	saved := append([]string{}, history...)
+	input := bufio.NewReader(g.Stdin)
+
+	// Plugins are run as child processes, so that the shell survives them.
+	execFn := g.ExecFn
+	g.ExecFn = g.childExec
+	defer func() { g.ExecFn = execFn }()
+
+	for {
+		fmt.Fprintf(g.Stdout, "%s> ", g.Name)
+
+		resume := g.ignoreInterrupts()
+		line, err := input.ReadString('\n')
+		resume()
+		if err != nil && line == "" {
+			fmt.Fprintln(g.Stdout)
+			return nil
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasSuffix(line, "?") || strings.HasSuffix(line, "\t") {
+			g.showCompletions(strings.TrimRight(line, "?\t"))
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "!") {
+			line, err = expandHistory(line, history)
+			if err != nil {
+				fmt.Fprintf(g.stderr(), "[ERROR] %s\n", err)
+				continue
+			}
+			fmt.Fprintln(g.Stdout, line)
+		}
+
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+
+		history = append(history, line)
+		saved = append(saved, g.redactLine(line))
+		g.saveHistory(saved)
+
+		if line == "history" {
+			for i, entry := range history {
+				fmt.Fprintf(g.Stdout, "%4d  %s\n", i+1, entry)
+			}
+			continue
+		}
+
+		args, err := splitArgs(line)
+		if err == nil {
+			err = g.runLine(args)
+		}
+
+		// Commands that reported their failure already are not reported
+		// once more.
+		if _, ok := err.(renderedError); err != nil && !ok {
+			fmt.Fprintf(g.stderr(), "[ERROR] %s\n", err)
+		}
+	}
+}
+
+// runLine runs command of the shell with a context of its own, so that
+// SIGINT only cancels that command, and not the shell.
+func (g *GatewayT) runLine(args []string) error {
+	ctx, stop := g.interruptible()
+	defer stop()
+
+	return g.RunContext(ctx, append([]string{g.Name}, args...))
+}
+
+// childExec runs plugin binary as a child process connected to the
+// gateway's input and output, and waits for it to finish.
+func (g *GatewayT) childExec(binary string, args []string, env []string) error {
+	cmd := exec.CommandContext(g.context(), binary)
+	cmd.Args = args
+	cmd.Env = env
+	cmd.Stdin = g.Stdin
+	cmd.Stdout = g.Stdout
+	cmd.Stderr = g.stderr()
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return &ExitError{Name: args[0], Code: exitErr.ExitCode()}
+	}
+
+	return err
+}
+
+// showCompletions lists commands or, for built-in commands, installed
+// plugins that the last word of line can be completed to.
+func (g *GatewayT) showCompletions(line string) {
+	words := strings.Fields(line)
+	prefix := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	candidates := []string{}
+	if len(words) == 0 {
+		for name := range builtinHandlers {
+			candidates = append(candidates, name)
+		}
+		candidates = append(candidates, "exit", "history")
+	}
+
+	if len(words) > 0 && builtinHandlers[words[0]] == nil {
+		candidates = append(candidates, g.completeArgs(words[0], words[1:], prefix)...)
+	}
+
+	if len(words) == 0 || builtinHandlers[words[0]] != nil {
+		plugins, _ := g.Plugins()
+		for _, p := range plugins {
+			candidates = append(candidates, p.Name)
+		}
+
+		aliases, _ := g.Aliases()
+		for alias := range aliases {
+			candidates = append(candidates, alias)
+		}
+	}
+
+	matches := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	sort.Strings(matches)
+	fmt.Fprintln(g.Stdout, strings.Join(matches, "  "))
+}
+
+// completeArgs asks plugins serving RPC to complete their arguments.
+func (g *GatewayT) completeArgs(name string, args []string, prefix string) []string {
+	var plugin *pluginT
+	err := g.Store.View(func(tx Tx) error {
+		var err error
+		plugin, err = resolvePlugin(tx, name)
+		return err
+	})
+	if err != nil || !plugin.Serve {
+		return nil
+	}
+
+	client, err := g.dialPlugin(plugin)
+	if err != nil {
+		fmt.Fprintf(g.stderr(), "[ERROR] %s\n", err)
+		return nil
+	}
+
+	completions := []string{}
+	if err := client.Call(rpcReceiverName+".Complete", &CompleteArgsT{Args: args, Prefix: prefix}, &completions); err != nil {
+		fmt.Fprintf(g.stderr(), "[ERROR] Plugin '%s' failed to complete arguments - %s\n", name, err)
+		return nil
+	}
+
+	return completions
+}
+
+// expandHistory replaces "!!" with the last command from history, and "!n"
+// with the n-th one.
+func expandHistory(line string, history []string) (string, error) {
+	n := len(history)
+	if line != "!!" {
+		var err error
+		if n, err = strconv.Atoi(line[1:]); err != nil {
+			return "", fmt.Errorf("Unknown history reference '%s'", line)
+		}
+	}
+
+	if n < 1 || n > len(history) {
+		return "", fmt.Errorf("No such command in history '%s'", line)
+	}
+
+	return history[n-1], nil
+}
+
+func (g *GatewayT) historyPath() string {
+	return g.Home + "/." + g.Name + "_history"
+}
+
+func (g *GatewayT) loadHistory() []string {
+	data, err := ioutil.ReadFile(g.historyPath())
+	if err != nil || len(data) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// redactLine hides sensitive arguments of shell command. It is kept as is,
+// unless there is something to hide.
+func (g *GatewayT) redactLine(line string) string {
+	args, err := splitArgs(line)
+	if err != nil || len(args) == 0 {
+		return g.redact(line)
+	}
+
+	p := &pluginT{}
+	g.Store.View(func(tx Tx) error {
+		if resolved, err := resolvePlugin(tx, args[0]); err == nil {
+			p = resolved
+		}
+		return nil
+	})
+
+	shown := joinArgs(append([]string{args[0]}, g.redactArgs(p, args[1:])...))
+	if shown == joinArgs(args) {
+		return g.redact(line)
+	}
+
+	return shown
+}
+
+// saveHistory writes recent history to the history file. Failing to do so
+// is not a reason to interrupt the shell, so errors are ignored.
+func (g *GatewayT) saveHistory(history []string) {
+	if len(history) > shellHistorySize {
+		history = history[len(history)-shellHistorySize:]
+	}
+
+	data := strings.Join(history, "\n") + "\n"
+	ioutil.WriteFile(g.historyPath(), []byte(data), os.FileMode(0600))
+}
@@ -0,0 +1,31 @@
+package plugged
+
+import (
+	"errors"
+)
+
+var errReadOnlyTx = errors.New("Unable to modify store in read-only transaction")
+
+// Store is a storage backend for the plugin registry.
+type Store interface {
+	View(fn func(tx Tx) error) error
+	Update(fn func(tx Tx) error) error
+	Close() error
+}
+
+// Tx is a transaction opened by the Store. Changes made in a transaction
+// passed to Store.Update are persisted only when fn returns nil.
+type Tx interface {
+	// Bucket returns nil when there is no bucket with such name.
+	Bucket(name string) Bucket
+	CreateBucketIfNotExists(name string) (Bucket, error)
+}
+
+// Bucket is a named collection of key/value pairs. ForEach iterates in
+// ascending key order.
+type Bucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	ForEach(fn func(key, value []byte) error) error
+}
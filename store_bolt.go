@@ -0,0 +1,68 @@
+package plugged
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+// boltLockTimeout bounds how long NewBoltStore waits for the database file
+// lock held by another process.
+const boltLockTimeout = 5 * time.Second
+
+// NewBoltStore opens (or creates) bolt database at path and uses it as a
+// Store.
+func NewBoltStore(path string) (Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltLockTimeout})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is locked by another process", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) View(fn func(tx Tx) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+func (s *boltStore) Update(fn func(tx Tx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func (t *boltTx) Bucket(name string) Bucket {
+	b := t.tx.Bucket([]byte(name))
+	if b == nil {
+		return nil
+	}
+
+	return b
+}
+
+func (t *boltTx) CreateBucketIfNotExists(name string) (Bucket, error) {
+	b, err := t.tx.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,122 @@
+package plugged
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type jsonFileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewJSONFileStore uses a plain JSON file at path as a Store. It does not
+// take any file locks, so it works where bolt can not lock its file, but
+// concurrent writers from different processes may overwrite each other's
+// changes.
+func NewJSONFileStore(path string) (Store, error) {
+	s := &jsonFileStore{path: path}
+
+	if _, err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *jsonFileStore) View(fn func(tx Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	return fn(&memoryTx{data: data})
+}
+
+func (s *jsonFileStore) Update(fn func(tx Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&memoryTx{data: data, writable: true}); err != nil {
+		return err
+	}
+
+	return s.dump(data)
+}
+
+func (s *jsonFileStore) Close() error {
+	return nil
+}
+
+func (s *jsonFileStore) load() (memoryData, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return memoryData{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read store file %s - %s", s.path, err)
+	}
+
+	raw := map[string]map[string]string{}
+	if err := json.Unmarshal(contents, &raw); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal store file %s - %s", s.path, err)
+	}
+
+	data := memoryData{}
+	for name, items := range raw {
+		data[name] = map[string][]byte{}
+		for key, value := range items {
+			data[name][key] = []byte(value)
+		}
+	}
+
+	return data, nil
+}
+
+func (s *jsonFileStore) dump(data memoryData) error {
+	raw := map[string]map[string]string{}
+	for name, items := range data {
+		raw[name] = map[string]string{}
+		for key, value := range items {
+			raw[name][key] = string(value)
+		}
+	}
+
+	contents, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to marshal store data to json - %s", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".")
+	if err != nil {
+		return fmt.Errorf("Unable to create temporary store file - %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Unable to write store file %s - %s", tmp.Name(), err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Unable to write store file %s - %s", tmp.Name(), err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("Unable to set permissions on store file %s - %s", tmp.Name(), err)
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
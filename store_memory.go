@@ -0,0 +1,124 @@
+package plugged
+
+import (
+	"sort"
+	"sync"
+)
+
+type memoryStore struct {
+	mu   sync.RWMutex
+	data memoryData
+}
+
+type memoryData map[string]map[string][]byte
+
+type memoryTx struct {
+	data     memoryData
+	writable bool
+}
+
+type memoryBucket struct {
+	items    map[string][]byte
+	writable bool
+}
+
+// NewMemoryStore creates an empty Store that lives only in memory. It is
+// useful for tests and for embedders that manage plugins on their own.
+func NewMemoryStore() Store {
+	return &memoryStore{data: memoryData{}}
+}
+
+func (s *memoryStore) View(fn func(tx Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return fn(&memoryTx{data: s.data})
+}
+
+func (s *memoryStore) Update(fn func(tx Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := s.data.clone()
+	if err := fn(&memoryTx{data: data, writable: true}); err != nil {
+		return err
+	}
+
+	s.data = data
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+func (d memoryData) clone() memoryData {
+	c := memoryData{}
+	for name, items := range d {
+		c[name] = map[string][]byte{}
+		for key, value := range items {
+			c[name][key] = value
+		}
+	}
+
+	return c
+}
+
+func (t *memoryTx) Bucket(name string) Bucket {
+	items, ok := t.data[name]
+	if !ok {
+		return nil
+	}
+
+	return &memoryBucket{items: items, writable: t.writable}
+}
+
+func (t *memoryTx) CreateBucketIfNotExists(name string) (Bucket, error) {
+	if !t.writable {
+		return nil, errReadOnlyTx
+	}
+
+	if _, ok := t.data[name]; !ok {
+		t.data[name] = map[string][]byte{}
+	}
+
+	return t.Bucket(name), nil
+}
+
+func (b *memoryBucket) Get(key []byte) []byte {
+	return b.items[string(key)]
+}
+
+func (b *memoryBucket) Put(key, value []byte) error {
+	if !b.writable {
+		return errReadOnlyTx
+	}
+
+	b.items[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (b *memoryBucket) Delete(key []byte) error {
+	if !b.writable {
+		return errReadOnlyTx
+	}
+
+	delete(b.items, string(key))
+	return nil
+}
+
+func (b *memoryBucket) ForEach(fn func(key, value []byte) error) error {
+	keys := []string{}
+	for key := range b.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := fn([]byte(key), b.items[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
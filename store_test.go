@@ -0,0 +1,92 @@
+package plugged
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	if err := os.MkdirAll("./tmp/store", 0777); err != nil {
+		t.Fatalf("Unable to create store directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/store")
+
+	examples := map[string]func() (Store, error){
+		"bolt":   func() (Store, error) { return NewBoltStore("./tmp/store/example.db") },
+		"json":   func() (Store, error) { return NewJSONFileStore("./tmp/store/example.json") },
+		"memory": func() (Store, error) { return NewMemoryStore(), nil },
+	}
+
+	for exampleName, newStore := range examples {
+		t.Log(exampleName)
+
+		func() {
+			store, err := newStore()
+			if err != nil {
+				t.Fatalf("Unable to create store - %s", err)
+			}
+			defer store.Close()
+
+			err = store.View(func(tx Tx) error {
+				if b := tx.Bucket("plugins"); b != nil {
+					t.Errorf("Expected no bucket, got %+v", b)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Update(func(tx Tx) error {
+				b, err := tx.CreateBucketIfNotExists("plugins")
+				if err != nil {
+					return err
+				}
+
+				for _, key := range []string{"find", "activate", "deploy"} {
+					if err := b.Put([]byte(key), []byte("value of "+key)); err != nil {
+						return err
+					}
+				}
+
+				return b.Delete([]byte("deploy"))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.Update(func(tx Tx) error {
+				b, err := tx.CreateBucketIfNotExists("plugins")
+				if err != nil {
+					return err
+				}
+
+				if err := b.Put([]byte("rollback"), []byte("value")); err != nil {
+					return err
+				}
+
+				return errReadOnlyTx
+			})
+			if err != errReadOnlyTx {
+				t.Errorf("Expected failed update to return its error, got %v", err)
+			}
+
+			actual := []string{}
+			err = store.View(func(tx Tx) error {
+				return tx.Bucket("plugins").ForEach(func(key, value []byte) error {
+					actual = append(actual, string(key)+"="+string(value))
+					return nil
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := []string{"activate=value of activate", "find=value of find"}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, actual)
+			}
+		}()
+	}
+}
@@ -0,0 +1,268 @@
+package plugged
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// defaultTemplates are templates of all views by the name they can be
+// overridden with, either in GatewayT.Templates or as <name>.tmpl file in
+// GatewayT.TemplateDir.
+var defaultTemplates = map[string]*template.Template{
+	"gatewayHelp":     gatewayHelpTemplate,
+	"commandList":     commandListTemplate,
+	"missingPlugin":   missingPluginTemplate,
+	"usageError":      usageErrorTemplate,
+	"importReport":    importReportTemplate,
+	"manifestWarning": manifestWarningTemplate,
+	"doctorReport":    doctorReportTemplate,
+	"pluginList":      pluginListTemplate,
+	"searchResult":    searchResultTemplate,
+	"picker":          pickerTemplate,
+	"statsReport":     statsReportTemplate,
+	"workflowSummary": workflowSummaryTemplate,
+	"markdownGateway": markdownGatewayTemplate,
+	"markdownPlugin":  markdownPluginTemplate,
+	"manGateway":      manGatewayTemplate,
+	"manPlugin":       manPluginTemplate,
+}
+
+// defaultTemplateFuncs make template functions known at parse time. They are
+// replaced by the ones bound to the gateway before execution.
+var defaultTemplateFuncs = template.FuncMap{
+	"color":     func(_ string, text interface{}) string { return fmt.Sprint(text) },
+	"colorOn":   func(_ string) string { return "" },
+	"colorOff":  func() string { return "" },
+	"wrap":      wrapText,
+	"indent":    indentText,
+	"group":     groupPlugins,
+	"manEscape": manEscape,
+	"redact":    func(text string) string { return text },
+}
+
+var ansiColors = map[string]string{
+	"bold":    "1",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"gray":    "90",
+}
+
+// groupT is a named group of plugins, as returned by group template function.
+type groupT struct {
+	Name    string
+	Plugins []*pluginT
+}
+
+// template returns view template with overrides and template functions
+// applied.
+func (g *GatewayT) template(name string) *template.Template {
+	if t, ok := g.templates[name]; ok {
+		return t
+	}
+
+	return defaultTemplates[name]
+}
+
+func (g *GatewayT) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, fn := range defaultTemplateFuncs {
+		funcs[name] = fn
+	}
+
+	funcs["color"] = g.colorize
+	funcs["colorOn"] = g.colorOn
+	funcs["colorOff"] = g.colorOff
+	funcs["redact"] = g.redact
+
+	for name, fn := range g.TemplateFuncs {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// loadTemplates prepares all view templates, taking overrides into account.
+func (g *GatewayT) loadTemplates() error {
+	if g.templates != nil {
+		return nil
+	}
+
+	for name := range g.Templates {
+		if _, ok := defaultTemplates[name]; !ok {
+			return fmt.Errorf("Unknown template '%s'", name)
+		}
+	}
+
+	funcs := g.templateFuncs()
+	templates := map[string]*template.Template{}
+
+	for name, fallback := range defaultTemplates {
+		source, err := g.templateOverride(name)
+		if err != nil {
+			return err
+		}
+
+		if source == "" {
+			t, err := fallback.Clone()
+			if err != nil {
+				return fmt.Errorf("Unable to clone template '%s' - %s", name, err)
+			}
+
+			templates[name] = t.Funcs(funcs)
+			continue
+		}
+
+		t, err := template.New(name).Funcs(funcs).Parse(source)
+		if err != nil {
+			return fmt.Errorf("Unable to parse template '%s' - %s", name, err)
+		}
+
+		templates[name] = t
+	}
+
+	g.templates = templates
+	return nil
+}
+
+func (g *GatewayT) templateOverride(name string) (string, error) {
+	if source, ok := g.Templates[name]; ok {
+		return source, nil
+	}
+
+	if g.TemplateDir == "" {
+		return "", nil
+	}
+
+	path := filepath.Join(g.TemplateDir, name+".tmpl")
+
+	source, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Unable to read template %s - %s", path, err)
+	}
+
+	return string(source), nil
+}
+
+// colorize wraps text into ANSI escape sequences of given color, when color
+// output is enabled. Multiple colors can be combined, eg. "bold,red".
+func (g *GatewayT) colorize(color string, text interface{}) string {
+	on := g.colorOn(color)
+	if on == "" {
+		return fmt.Sprint(text)
+	}
+
+	// Every line is colored separately, so that escape sequences do not
+	// span multiple lines.
+	lines := strings.Split(fmt.Sprint(text), "\n")
+	for i, line := range lines {
+		lines[i] = on + line + g.colorOff()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// colorOn starts coloring all of the following text, until colorOff.
+func (g *GatewayT) colorOn(color string) string {
+	if !g.colorEnabled() {
+		return ""
+	}
+
+	codes := []string{}
+	for _, name := range strings.Split(color, ",") {
+		if code, ok := ansiColors[strings.TrimSpace(name)]; ok {
+			codes = append(codes, code)
+		}
+	}
+
+	if len(codes) == 0 {
+		return ""
+	}
+
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+func (g *GatewayT) colorOff() string {
+	if !g.colorEnabled() {
+		return ""
+	}
+
+	return "\x1b[0m"
+}
+
+// wrapText breaks text into lines of at most width characters, splitting at
+// whitespace. Width of zero or less leaves text as is.
+func wrapText(width int, text string) string {
+	if width <= 0 {
+		return text
+	}
+
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// indentText prefixes all lines of text but the first one.
+func indentText(prefix, text string) string {
+	return strings.Replace(text, "\n", "\n"+prefix, -1)
+}
+
+// groupPlugins groups plugins by value of the named field. Groups are sorted
+// by name, and plugins keep their order within a group.
+func groupPlugins(field string, plugins []*pluginT) ([]*groupT, error) {
+	groups := map[string]*groupT{}
+	names := []string{}
+
+	for _, p := range plugins {
+		value := reflect.ValueOf(p).Elem().FieldByName(field)
+		if !value.IsValid() {
+			return nil, fmt.Errorf("Plugin has no field '%s'", field)
+		}
+
+		name := fmt.Sprint(value.Interface())
+		if _, ok := groups[name]; !ok {
+			groups[name] = &groupT{Name: name}
+			names = append(names, name)
+		}
+
+		groups[name].Plugins = append(groups[name].Plugins, p)
+	}
+
+	sort.Strings(names)
+
+	result := []*groupT{}
+	for _, name := range names {
+		result = append(result, groups[name])
+	}
+
+	return result, nil
+}
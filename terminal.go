@@ -0,0 +1,40 @@
+package plugged
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// isTerminal tells if stream, either reader or writer, is connected to a
+// terminal.
+func isTerminal(stream interface{}) bool {
+	f, ok := stream.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return isatty(f)
+}
+
+// outputWidth returns width of the terminal w is connected to, or 0 when w
+// is not a terminal. COLUMNS environment variable takes precedence.
+func outputWidth(w io.Writer) int {
+	if !isTerminal(w) {
+		return 0
+	}
+
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	return terminalWidth(w.(*os.File))
+}
+
+func (g *GatewayT) colorEnabled() bool {
+	if g.noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return isTerminal(g.Stdout)
+}
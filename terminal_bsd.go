@@ -0,0 +1,8 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package plugged
+
+import "syscall"
+
+const ioctlReadTermios = syscall.TIOCGETA
@@ -0,0 +1,5 @@
+package plugged
+
+import "syscall"
+
+const ioctlReadTermios = syscall.TCGETS
@@ -0,0 +1,21 @@
+//go:build !darwin && !freebsd && !linux
+// +build !darwin,!freebsd,!linux
+
+package plugged
+
+import (
+	"os"
+)
+
+func terminalWidth(f *os.File) int {
+	return 0
+}
+
+func isatty(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,23 @@
+package plugged
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestIsTerminal(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	if isTerminal(devNull) {
+		t.Errorf("Expected %s not to be a terminal", os.DevNull)
+	}
+
+	if isTerminal(&bytes.Buffer{}) {
+		t.Errorf("Expected buffer not to be a terminal")
+	}
+}
@@ -0,0 +1,43 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package plugged
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func terminalWidth(f *os.File) int {
+	var size struct {
+		Rows, Cols, XPixels, YPixels uint16
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		f.Fd(),
+		uintptr(syscall.TIOCGWINSZ),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if errno != 0 {
+		return 0
+	}
+
+	return int(size.Cols)
+}
+
+// isatty asks the terminal driver for f's settings, which only succeeds for
+// terminals, unlike checking for a character device, eg. /dev/null.
+func isatty(f *os.File) bool {
+	var termios syscall.Termios
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		f.Fd(),
+		uintptr(ioctlReadTermios),
+		uintptr(unsafe.Pointer(&termios)),
+	)
+
+	return errno == 0
+}
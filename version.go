@@ -0,0 +1,131 @@
+package plugged
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// versionSatisfies checks version against constraint. Constraint is a
+// comma-separated list of terms, each of them is a version optionally
+// prefixed with one of =, !=, >, >=, <, <=, ~ or ^. Empty constraint and "*"
+// match any version.
+func versionSatisfies(version, constraint string) (bool, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" || constraint == "*" {
+		return true, nil
+	}
+
+	if version == "" {
+		return false, nil
+	}
+
+	actual, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, term := range strings.Split(constraint, ",") {
+		ok, err := termSatisfied(actual, strings.TrimSpace(term))
+		if err != nil {
+			return false, fmt.Errorf("Invalid version constraint '%s' - %s", constraint, err)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func termSatisfied(actual []int, term string) (bool, error) {
+	op := term[:len(term)-len(strings.TrimLeft(term, "=!<>~^"))]
+
+	expected, err := parseVersion(strings.TrimSpace(term[len(op):]))
+	if err != nil {
+		return false, err
+	}
+
+	cmp := compareVersions(actual, expected)
+
+	switch op {
+	case "", "=", "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case "~":
+		return cmp >= 0 && compareVersions(actual, bumpVersion(expected, 1)) < 0, nil
+	case "^":
+		position := 0
+		for position < len(expected)-1 && expected[position] == 0 {
+			position++
+		}
+		return cmp >= 0 && compareVersions(actual, bumpVersion(expected, position)) < 0, nil
+	}
+
+	return false, fmt.Errorf("Unknown operator '%s'", op)
+}
+
+func parseVersion(version string) ([]int, error) {
+	version = strings.TrimPrefix(version, "v")
+
+	// Pre-release and build suffixes are not taken into account.
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	parts := strings.Split(version, ".")
+	numbers := make([]int, len(parts))
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("Unable to parse version '%s'", version)
+		}
+		numbers[i] = n
+	}
+
+	return numbers, nil
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// bumpVersion increments version segment at position and drops all of the
+// following ones, eg. bumping 1.2.3 at 1 gives 1.3.
+func bumpVersion(version []int, position int) []int {
+	if position >= len(version) {
+		position = len(version) - 1
+	}
+
+	bumped := append([]int{}, version[:position+1]...)
+	bumped[position]++
+	return bumped
+}
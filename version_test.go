@@ -0,0 +1,53 @@
+package plugged
+
+import (
+	"testing"
+)
+
+func TestVersionSatisfies(t *testing.T) {
+	examples := []struct {
+		version    string
+		constraint string
+		expected   bool
+	}{
+		{"1.2.3", "", true},
+		{"", "*", true},
+		{"", ">=1.0", false},
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "=1.2", false},
+		{"1.2.0", "=1.2", true},
+		{"1.2.3", "!=1.2.3", false},
+		{"1.2.3", ">=1.2, <2", true},
+		{"2.0.0", ">=1.2, <2", false},
+		{"1.10.0", ">1.9", true},
+		{"1.2.9", "~1.2.3", true},
+		{"1.3.0", "~1.2.3", false},
+		{"1.9.0", "^1.2", true},
+		{"2.0.0", "^1.2", false},
+		{"0.2.5", "^0.2.1", true},
+		{"0.3.0", "^0.2.1", false},
+		{"1.2.3-beta", "<=1.2.3", true},
+	}
+
+	for _, example := range examples {
+		actual, err := versionSatisfies(example.version, example.constraint)
+		if err != nil {
+			t.Errorf("%s against %s: unexpected error - %s", example.version, example.constraint, err)
+			continue
+		}
+
+		if actual != example.expected {
+			t.Errorf(
+				"%s against %s: expected %v, got %v",
+				example.version,
+				example.constraint,
+				example.expected,
+				actual,
+			)
+		}
+	}
+
+	if _, err := versionSatisfies("1.2.3", "=>1.0"); err == nil {
+		t.Errorf("Expected error for invalid constraint")
+	}
+}
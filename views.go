@@ -8,10 +8,10 @@ import (
 	"text/template"
 )
 
-var gatewayHelpTemplate = template.Must(template.New("gatewayHelpView").Parse(
+var gatewayHelpTemplate = template.Must(template.New("gatewayHelpView").Funcs(defaultTemplateFuncs).Parse(
 	`USAGE: {{.Name}} command [options]
 
-{{.Name}} - {{.Description}}
+{{color "bold" .Name}} - {{.Description}}
 
 Available commands:
 {{.AvailableCommands}}
@@ -27,26 +27,64 @@ type helpView struct {
 	AvailableCommands string
 }
 
-func (v *helpView) render(w io.Writer) error {
-	if err := gatewayHelpTemplate.Execute(w, v); err != nil {
+func (v *helpView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
 		return fmt.Errorf("Unable to execute helpView template on %v - %s", v, err)
 	}
 	return nil
 }
 
-var commandListTemplate = template.Must(template.New("commandListView").Parse(
-	"{{range .PluginList}}\n- {{.Name}}\t - {{.Description}}{{end}}\n- help\t - This info.",
+var commandListTemplate = template.Must(template.New("commandListView").Funcs(defaultTemplateFuncs).Parse(
+	// Escape sequences of command names are kept out of tabwriter cells
+	// by switching color on before the line break and off after the tab,
+	// otherwise they would be counted as part of the cell width.
+	`{{range $i, $group := .Groups}}{{if .Name}}{{if $i}}
+{{end}}
+{{color "bold" .Name}}:{{end}}{{range .Commands}}{{colorOn "bold,cyan"}}
+- {{.Name}}` + "\t" + `{{colorOff}} - {{indent "\t   " (color "gray" (wrap $.DescriptionWidth .Description))}}{{end}}{{end}}`,
 ))
 
 type commandListView struct {
+	// PluginList is kept for custom templates, Groups include built-in
+	// commands too.
 	PluginList []*pluginT
+	Groups     []*commandGroupT
+
+	// DescriptionWidth is how wide descriptions can be to fit into the
+	// terminal. Zero means they are not wrapped.
+	DescriptionWidth int
 }
 
-func (v *commandListView) render() (string, error) {
+// descriptionWidth calculates how much space is left for plugin descriptions
+// in command list on a terminal of given width. Tabs are expected to stop at
+// every 8th column.
+func descriptionWidth(width int, commands []*commandT) int {
+	if width <= 0 {
+		return 0
+	}
+
+	name := 0
+	for _, c := range commands {
+		if len(c.Name) > name {
+			name = len(c.Name)
+		}
+	}
+
+	column := (len("- ")+name)/8*8 + 8
+	available := width - column - len(" - ")
+
+	if available < 20 {
+		return 0
+	}
+
+	return available
+}
+
+func (v *commandListView) render(t *template.Template) (string, error) {
 	buf := &bytes.Buffer{}
-	w := tabwriter.NewWriter(buf, 0, 4, 0, '\t', 0)
+	w := tabwriter.NewWriter(buf, 0, 8, 0, '\t', 0)
 
-	if err := commandListTemplate.Execute(w, v); err != nil {
+	if err := t.Execute(w, v); err != nil {
 		return "", fmt.Errorf("Unable to execute commandList template on %v - %s", v, err)
 	}
 
@@ -57,8 +95,8 @@ func (v *commandListView) render() (string, error) {
 	return string(buf.Bytes()), nil
 }
 
-var missingPluginTemplate = template.Must(template.New("missingPluginView").Parse(
-	`[ERROR] Unable to find plugin '{{.Name}}'.
+var missingPluginTemplate = template.Must(template.New("missingPluginView").Funcs(defaultTemplateFuncs).Parse(
+	`{{color "bold,red" "[ERROR]"}} Unable to find plugin '{{.Name}}'.
 Try installing it with '{{.AppName}} --plugged-install {{.Name}}'.
 Details: {{.Details}}
 
@@ -71,9 +109,291 @@ type missingPluginView struct {
 	Details string
 }
 
-func (v *missingPluginView) render(w io.Writer) error {
-	if err := missingPluginTemplate.Execute(w, v); err != nil {
+func (v *missingPluginView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
 		return fmt.Errorf("Unable to execute missingPlugin template on %v - %s", v, err)
 	}
 	return nil
 }
+
+var usageErrorTemplate = template.Must(template.New("usageErrorView").Funcs(defaultTemplateFuncs).Parse(
+	`{{color "bold,red" "[ERROR]"}} Wrong arguments.
+USAGE: {{.AppName}} {{.Usage}}
+`,
+))
+
+type usageErrorView struct {
+	AppName string
+	Usage   string
+}
+
+func (v *usageErrorView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
+		return fmt.Errorf("Unable to execute usageError template on %v - %s", v, err)
+	}
+	return nil
+}
+
+var importReportTemplate = template.Must(template.New("importReportView").Funcs(defaultTemplateFuncs).Parse(
+	`{{range .Entries}}{{$name := .Name}}{{if .Error}}{{$name}}: not imported - {{.Error}}
+{{else if .Differences}}{{range .Differences}}{{$name}}: {{.}}
+{{end}}{{else}}{{$name}}: up to date
+{{end}}{{end}}{{range $alias, $name := .Aliases}}{{$alias}}: alias for {{$name}}
+{{end}}`,
+))
+
+type importReportView struct {
+	Entries []*importEntryT
+	Aliases map[string]string
+}
+
+type importEntryT struct {
+	Name        string
+	Error       string
+	Differences []string
+}
+
+func (v *importReportView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
+		return fmt.Errorf("Unable to execute importReport template on %v - %s", v, err)
+	}
+	return nil
+}
+
+var manifestWarningTemplate = template.Must(template.New("manifestWarningView").Funcs(defaultTemplateFuncs).Parse(
+	`{{color "bold,yellow" "[WARNING]"}} Plugins required by {{.Path}} are not installed properly:
+{{range .Requirements}}- {{.Name}}{{if .Constraint}} ({{.Constraint}}){{end}}: {{.Problem}}
+{{end}}Run '{{.AppName}} --plugged-sync' to install them.
+
+`,
+))
+
+type manifestWarningView struct {
+	AppName      string
+	Path         string
+	Requirements []*requirementT
+}
+
+func (v *manifestWarningView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
+		return fmt.Errorf("Unable to execute manifestWarning template on %v - %s", v, err)
+	}
+	return nil
+}
+
+var doctorReportTemplate = template.Must(template.New("doctorReportView").Funcs(defaultTemplateFuncs).Parse(
+	`{{range .Checks}}{{.Subject}}: {{.Status}}{{if .Details}} - {{.Details}}{{end}}
+{{end}}`,
+))
+
+type doctorReportView struct {
+	Checks []*checkT
+}
+
+func (v *doctorReportView) render(t *template.Template, w io.Writer) error {
+	if err := t.Execute(w, v); err != nil {
+		return fmt.Errorf("Unable to execute doctorReport template on %v - %s", v, err)
+	}
+	return nil
+}
+
+var pluginListTemplate = template.Must(template.New("pluginListView").Funcs(defaultTemplateFuncs).Parse(
+	`{{range .PluginList}}{{.Name}}	{{if .Version}}{{.Version}}{{else}}-{{end}}	{{.Path}}
+{{end}}`,
+))
+
+type pluginListView struct {
+	PluginList []*pluginT
+}
+
+func (v *pluginListView) render(t *template.Template, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+
+	if err := t.Execute(tw, v); err != nil {
+		return fmt.Errorf("Unable to execute pluginList template on %v - %s", v, err)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush tabwriter - %s", err)
+	}
+
+	return nil
+}
+
+var searchResultTemplate = template.Must(template.New("searchResultView").Funcs(defaultTemplateFuncs).Parse(
+	`{{range .Results}}{{if .Installed}}*{{else}} {{end}} {{.Name}}	 - {{.Description}}
+{{else}}No plugins found.
+{{end}}`,
+))
+
+type searchResultView struct {
+	Results []*foundT
+}
+
+func (v *searchResultView) render(t *template.Template, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 0, '\t', 0)
+
+	if err := t.Execute(tw, v); err != nil {
+		return fmt.Errorf("Unable to execute searchResult template on %v - %s", v, err)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush tabwriter - %s", err)
+	}
+
+	return nil
+}
+
+var pickerTemplate = template.Must(template.New("pickerView").Funcs(defaultTemplateFuncs).Parse(
+	`{{range .Matches}}{{.Number}})	{{.Plugin.Name}}	- {{color "gray" .Plugin.Description}}
+{{end}}`,
+))
+
+type pickerView struct {
+	Matches []*pickedT
+}
+
+func (v *pickerView) render(t *template.Template, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+
+	if err := t.Execute(tw, v); err != nil {
+		return fmt.Errorf("Unable to execute picker template on %v - %s", v, err)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush tabwriter - %s", err)
+	}
+
+	return nil
+}
+
+var statsReportTemplate = template.Must(template.New("statsReportView").Funcs(defaultTemplateFuncs).Parse(
+	`{{with .Stats}}{{if .Plugins}}PLUGIN	RUNS	FAILED	AVG TIME	MAX TIME
+{{range .Plugins}}{{.Plugin}}	{{.Runs}}	{{if .Completed}}{{.Failures}}/{{.Completed}}	{{.AverageTime}}	{{.MaxTime}}{{else}}-	-	-{{end}}
+{{end}}{{else}}No plugin invocations recorded.
+{{end}}{{if .Slowest}}
+Slowest invocations:
+{{range .Slowest}}{{.Duration}}	{{.Plugin}}{{range .Args}} {{.}}{{end}}
+{{end}}{{end}}{{end}}`,
+))
+
+type statsReportView struct {
+	Stats *statsT
+}
+
+func (v *statsReportView) render(t *template.Template, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if err := t.Execute(tw, v); err != nil {
+		return fmt.Errorf("Unable to execute statsReport template on %v - %s", v, err)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush tabwriter - %s", err)
+	}
+
+	return nil
+}
+
+var markdownGatewayTemplate = template.Must(template.New("markdownGatewayView").Funcs(defaultTemplateFuncs).Parse(
+	`# {{.Name}}
+
+{{.Description}}
+
+## Usage
+
+    {{.Name}} command [options]
+
+## Commands
+{{range .Groups}}{{if .Name}}
+### {{.Name}}
+{{end}}
+{{range .Commands}}- [{{.Name}}]({{$.Name}}-{{.Name}}.md) - {{.Description}}
+{{end}}{{end}}`,
+))
+
+var markdownPluginTemplate = template.Must(template.New("markdownPluginView").Funcs(defaultTemplateFuncs).Parse(
+	`# {{.AppName}} {{.Plugin.Name}}
+
+{{.Plugin.Description}}
+{{if .Plugin.Version}}
+Version: {{.Plugin.Version}}
+{{end}}
+## Usage
+
+{{if .Help}}    {{indent "    " .Help}}{{else}}No help available.{{end}}
+
+See also [{{.AppName}}]({{.AppName}}.md).
+`,
+))
+
+var manGatewayTemplate = template.Must(template.New("manGatewayView").Funcs(defaultTemplateFuncs).Parse(
+	`.TH "{{manEscape .Name}}" 1
+.SH NAME
+{{manEscape .Name}} \- {{manEscape .Description}}
+.SH SYNOPSIS
+.B {{manEscape .Name}}
+command [options]
+.SH COMMANDS
+{{range .Groups}}{{if .Name}}.SS {{manEscape .Name}}
+{{end}}{{range .Commands}}.TP
+.B {{manEscape .Name}}
+{{manEscape .Description}}
+{{end}}{{end}}.SH SEE ALSO
+{{range .Groups}}{{range .Commands}}.BR {{manEscape $.Name}}-{{manEscape .Name}} (1)
+{{end}}{{end}}`,
+))
+
+var manPluginTemplate = template.Must(template.New("manPluginView").Funcs(defaultTemplateFuncs).Parse(
+	`.TH "{{manEscape .Command}}" 1 "" "{{manEscape .Plugin.Name}}{{if .Plugin.Version}} {{manEscape .Plugin.Version}}{{end}}"
+.SH NAME
+{{manEscape .Command}} \- {{manEscape .Plugin.Description}}
+.SH SYNOPSIS
+.B {{manEscape .AppName}} {{manEscape .Plugin.Name}}
+[arguments]
+.SH DESCRIPTION
+{{if .Help}}.nf
+{{manEscape .Help}}
+.fi{{else}}No help available.{{end}}
+.SH SEE ALSO
+.BR {{manEscape .AppName}} (1)
+`,
+))
+
+type gatewayPageView struct {
+	Name        string
+	Description string
+	Groups      []*commandGroupT
+}
+
+type pluginPageView struct {
+	AppName string
+	Command string
+	Plugin  *pluginT
+	Help    string
+}
+
+var workflowSummaryTemplate = template.Must(template.New("workflowSummaryView").Funcs(defaultTemplateFuncs).Parse(
+	`
+Workflow '{{.Workflow.Name}}':
+{{range .Workflow.Steps}}{{.}}	{{if eq .Status "ok"}}{{color "green" .Status}}{{else if eq .Status "failed"}}{{color "red" .Status}}{{else}}{{color "gray" .Status}}{{end}}{{if .Details}}	{{.Details}}{{end}}
+{{end}}`,
+))
+
+type workflowSummaryView struct {
+	Workflow *workflowT
+}
+
+func (v *workflowSummaryView) render(t *template.Template, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if err := t.Execute(tw, v); err != nil {
+		return fmt.Errorf("Unable to execute workflowSummary template on %v - %s", v, err)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("Unable to flush tabwriter - %s", err)
+	}
+
+	return nil
+}
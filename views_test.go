@@ -0,0 +1,47 @@
+package plugged
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCommandListViewWrapsDescriptions(t *testing.T) {
+	g := &GatewayT{Stdout: &bytes.Buffer{}}
+	if err := g.loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := []*commandT{
+		{Name: "find", Description: "Find some stuff in all the places you can think of."},
+		{Name: "help", Description: "This info.", Builtin: true},
+	}
+
+	if width := descriptionWidth(0, commands); width != 0 {
+		t.Errorf("Expected no wrapping when width is unknown, got %d", width)
+	}
+
+	if width := descriptionWidth(30, commands); width != 0 {
+		t.Errorf("Expected no wrapping on too narrow terminal, got %d", width)
+	}
+
+	view := &commandListView{
+		Groups:           groupCommands(commands),
+		DescriptionWidth: descriptionWidth(31, commands),
+	}
+
+	actual, err := view.render(g.template("commandList"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := dedent(`
+              |
+              |- find\t - Find some stuff in
+              |\t   all the places you
+              |\t   can think of.
+      `) + "- help\t - This info."
+
+	if actual != expected {
+		t.Errorf("\nExpected:\n%q\nActual:\n%q", expected, actual)
+	}
+}
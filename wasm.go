@@ -0,0 +1,12 @@
+package plugged
+
+import "strings"
+
+// wasmExtension is the extension of WebAssembly plugins, eg.
+// "appname-find.wasm". They are run inside an embedded WASI runtime instead
+// of being executed.
+const wasmExtension = ".wasm"
+
+func isWasm(binary string) bool {
+	return strings.HasSuffix(binary, wasmExtension)
+}
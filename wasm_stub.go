@@ -0,0 +1,16 @@
+//go:build !plugged_wasm
+// +build !plugged_wasm
+
+package plugged
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// runWasm is not available unless built with plugged_wasm tag, which needs
+// github.com/tetratelabs/wazero.
+func runWasm(_ context.Context, path string, _, _ []string, _ io.Reader, _, _ io.Writer, _ []string) error {
+	return fmt.Errorf("Unable to run %s - WebAssembly plugins need building with -tags plugged_wasm", path)
+}
@@ -0,0 +1,90 @@
+package plugged
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFindBinaryOfWasmPlugin(t *testing.T) {
+	if err := os.MkdirAll("./tmp/wasm", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/wasm")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/wasm:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	if err := ioutil.WriteFile("./tmp/wasm/exampleapp-find.wasm", []byte("\x00asm"), 0644); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	binary, err := (&GatewayT{}).findBinary("exampleapp-find")
+	if err != nil || binary != "tmp/wasm/exampleapp-find.wasm" {
+		t.Errorf("Expected wasm plugin to be found, got %q, %v", binary, err)
+	}
+
+	if _, err := (&GatewayT{}).findBinary("exampleapp-activate"); err == nil {
+		t.Errorf("Expected missing plugin not to be found")
+	}
+
+	found := (&GatewayT{Name: "exampleapp"}).discoverPlugins()
+	if len(found) != 1 || found[0].Name != "find" {
+		t.Errorf("Expected wasm plugin to be discovered as 'find', got %+v", found)
+	}
+}
+
+func TestDirsOfWasmPluginRequirePermission(t *testing.T) {
+	p := newPlugin("exampleapp", "find")
+	p.Dirs = []string{"/srv/data"}
+	p.Permissions = &permissionsT{Network: true}
+
+	expected := "- access the network\n- access directories: /srv/data"
+	if actual := p.requested().describe(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+
+	p.Approved = p.Permissions
+	if err := (&GatewayT{Sandbox: true}).sandboxed(p, &launchT{Binary: "exampleapp-find.wasm"}); err == nil {
+		t.Errorf("Expected plugin with unapproved dirs not to run")
+	}
+
+	p.Approved = p.requested()
+	if dirs := p.Approved.dirs(); len(dirs) != 1 || dirs[0] != "/srv/data" {
+		t.Errorf("Expected approved dirs, got %v", dirs)
+	}
+}
+
+func TestFindBinaryInPluginDirsBeforePath(t *testing.T) {
+	for _, dir := range []string{"./tmp/dirs/bin", "./tmp/dirs/plugins"} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatalf("Unable to create directory %s - %s", dir, err)
+		}
+	}
+	defer os.RemoveAll("./tmp/dirs")
+
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/dirs/bin:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	if err := ioutil.WriteFile("./tmp/dirs/bin/exampleapp-find", []byte("#!/usr/bin/env sh\n"), 0777); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	if err := ioutil.WriteFile("./tmp/dirs/plugins/exampleapp-find.py", []byte("print()\n"), 0644); err != nil {
+		t.Fatalf("Unable to create plugin - %s", err)
+	}
+
+	g := &GatewayT{Name: "exampleapp", PluginDirs: []string{"./tmp/dirs/plugins"}}
+
+	binary, err := g.findBinary("exampleapp-find")
+	if err != nil || binary != "tmp/dirs/plugins/exampleapp-find.py" {
+		t.Errorf("Expected script in PluginDirs to be found, got %q, %v", binary, err)
+	}
+
+	found := g.discoverPlugins()
+	if len(found) != 1 || found[0].Path != binary {
+		t.Errorf("Expected discovered plugin to be the one found, got %+v", found)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build plugged_wasm
+// +build plugged_wasm
+
+package plugged
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// runWasm runs WebAssembly plugin at path with args, where args[0] is the
+// command name, env and standard streams, like exec would do. Plugin can
+// only access the filesystem in dirs.
+func runWasm(ctx context.Context, path string, args, env []string, stdin io.Reader, stdout, stderr io.Writer, dirs []string) error {
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read %s - %s", path, err)
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	defer runtime.Close(ctx)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fmt.Errorf("Unable to instantiate WASI - %s", err)
+	}
+
+	fs := wazero.NewFSConfig()
+	for _, dir := range dirs {
+		fs = fs.WithDirMount(dir, dir)
+	}
+
+	config := wazero.NewModuleConfig().
+		WithArgs(args...).
+		WithFSConfig(fs).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	if stdin != nil {
+		config = config.WithStdin(stdin)
+	}
+	if stdout != nil {
+		config = config.WithStdout(stdout)
+	}
+	if stderr != nil {
+		config = config.WithStderr(stderr)
+	}
+
+	for _, variable := range env {
+		if eq := strings.Index(variable, "="); eq > 0 {
+			config = config.WithEnv(variable[:eq], variable[eq+1:])
+		}
+	}
+
+	_, err = runtime.InstantiateWithConfig(ctx, code, config)
+	if exitErr, ok := err.(*sys.ExitError); ok {
+		if exitErr.ExitCode() == 0 {
+			return nil
+		}
+
+		return &ExitError{Name: args[0], Code: int(exitErr.ExitCode())}
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+//go:build plugged_wasm
+// +build plugged_wasm
+
+package plugged
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// wasmPlugin prints its arguments, a variable, standard input, and whether
+// files named by arguments can be read, then exits with status 3.
+const wasmPlugin = `package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	fmt.Println(strings.Join(os.Args, " "))
+	fmt.Println(os.Getenv("WASM_VARIABLE"))
+
+	input, _ := io.ReadAll(os.Stdin)
+	fmt.Print(string(input))
+
+	for _, path := range os.Args[1:] {
+		if _, err := os.ReadFile(path); err != nil {
+			fmt.Println("denied")
+		} else {
+			fmt.Println("read")
+		}
+	}
+
+	os.Exit(3)
+}
+`
+
+// buildWasmPlugin compiles wasmPlugin to a WASI module at path.
+func buildWasmPlugin(t *testing.T, path string) {
+	source := filepath.Join(filepath.Dir(path), "main.go")
+	if err := ioutil.WriteFile(source, []byte(wasmPlugin), 0644); err != nil {
+		t.Fatalf("Unable to create plugin source - %s", err)
+	}
+
+	cmd := exec.Command("go", "build", "-o", path, source)
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOFLAGS=")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Unable to build plugin - %s\n%s", err, output)
+	}
+}
+
+func TestRunWasm(t *testing.T) {
+	if err := os.MkdirAll("./tmp/wazero/bin", 0777); err != nil {
+		t.Fatalf("Unable to create path directory - %s", err)
+	}
+	defer os.RemoveAll("./tmp/wazero")
+
+	allowed, _ := filepath.Abs("./tmp/wazero/allowed")
+	denied, _ := filepath.Abs("./tmp/wazero/denied")
+	for _, dir := range []string{allowed, denied} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatalf("Unable to create directory - %s", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte("data"), 0644); err != nil {
+			t.Fatalf("Unable to create file - %s", err)
+		}
+	}
+
+	binary := "./tmp/wazero/bin/exampleapp-find.wasm"
+	buildWasmPlugin(t, binary)
+
+	stdout := &bytes.Buffer{}
+	args := []string{"exampleapp-find", filepath.Join(allowed, "file"), filepath.Join(denied, "file")}
+	env := []string{"WASM_VARIABLE=value"}
+
+	err := runWasm(context.Background(), binary, args, env, bytes.NewBufferString("input\n"), stdout, nil, []string{allowed})
+
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 3 {
+		t.Errorf("Expected exit status 3, got %v", err)
+	}
+
+	expected := args[0] + " " + args[1] + " " + args[2] + "\nvalue\ninput\nread\ndenied\n"
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+
+	// Sandboxed plugin only accesses directories user approved.
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", "./tmp/wazero/bin:"+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	stdout.Reset()
+	g := &GatewayT{
+		Stdin:   bytes.NewBufferString(""),
+		Stdout:  stdout,
+		Home:    "./tmp/wazero",
+		Name:    "exampleapp",
+		Store:   NewMemoryStore(),
+		Sandbox: true,
+	}
+
+	p := newPlugin("exampleapp", "find")
+	p.Dirs = []string{allowed, denied}
+	p.Approved = &permissionsT{Dirs: []string{allowed}}
+
+	if err := p.run(g, args[1:]); err == nil {
+		t.Errorf("Expected plugin with unapproved dirs not to run")
+	}
+
+	p.Dirs = []string{allowed}
+	err = p.run(g, args[1:])
+
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Name != "find" || exitErr.Code != 3 {
+		t.Errorf("Expected plugin 'find' to exit with status 3, got %v", err)
+	}
+
+	expected = args[0] + " " + args[1] + " " + args[2] + "\n\nread\ndenied\n"
+	if actual := stdout.String(); actual != expected {
+		t.Errorf("\nExpected: %q\nActual:   %q", expected, actual)
+	}
+}
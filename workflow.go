@@ -0,0 +1,297 @@
+package plugged
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	stepOK      = "ok"
+	stepFailed  = "failed"
+	stepSkipped = "skipped"
+)
+
+// stepT is a single command of a workflow. Op is how it is chained to the
+// previous step: "&&" runs it only when the previous one succeeded, and ";"
+// runs it regardless.
+type stepT struct {
+	Op      string
+	Args    []string
+	Status  string
+	Details string
+
+	// Shown is how step is shown, with sensitive arguments hidden, see
+	// redactSteps.
+	Shown string
+}
+
+func (s *stepT) String() string {
+	if s.Shown != "" {
+		return s.Shown
+	}
+
+	return strings.Join(s.Args, " ")
+}
+
+// workflowT is a named chain of commands, eg. "lint && test ; publish".
+type workflowT struct {
+	Name  string
+	Steps []*stepT
+}
+
+// parseWorkflow splits definition into steps. Separators have to be
+// surrounded with whitespace, and arguments can be quoted.
+func parseWorkflow(name, definition string) (*workflowT, error) {
+	words, err := splitArgs(definition)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse workflow '%s' - %s", name, err)
+	}
+
+	workflow := &workflowT{Name: name}
+	step := &stepT{Op: ";"}
+
+	for _, word := range append(words, ";") {
+		if word != "&&" && word != ";" {
+			step.Args = append(step.Args, word)
+			continue
+		}
+
+		if len(step.Args) == 0 {
+			return nil, fmt.Errorf("Unable to parse workflow '%s' - empty step before '%s'", name, word)
+		}
+
+		workflow.Steps = append(workflow.Steps, step)
+		step = &stepT{Op: word}
+	}
+
+	return workflow, nil
+}
+
+// Workflows returns definitions of workflows by name: the ones defined with
+// --plugged-workflow, and GatewayT.BuiltinWorkflows not overridden by them.
+func (g *GatewayT) Workflows() (map[string]string, error) {
+	workflows := map[string]string{}
+	for name, definition := range g.BuiltinWorkflows {
+		workflows[name] = definition
+	}
+
+	err := g.Store.View(func(tx Tx) error {
+		b := tx.Bucket("workflows")
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(name, definition []byte) error {
+			workflows[string(name)] = string(definition)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get workflows - %s", err)
+	}
+
+	return workflows, nil
+}
+
+func (g *GatewayT) workflowAction(action string, args []string) error {
+	switch {
+	case len(args) == 0:
+		return g.listWorkflows()
+	case len(args) == 2 && args[1] == "--remove":
+		return g.updateWorkflow(args[0], "")
+	case len(args) >= 2:
+		definition := strings.Join(args[1:], " ")
+		if _, err := parseWorkflow(args[0], definition); err != nil {
+			return err
+		}
+
+		return g.updateWorkflow(args[0], definition)
+	}
+
+	return g.showUsage(action + " [name definition | name --remove]")
+}
+
+func (g *GatewayT) updateWorkflow(name, definition string) error {
+	return g.Store.Update(func(tx Tx) error {
+		b, err := tx.CreateBucketIfNotExists("workflows")
+		if err != nil {
+			return fmt.Errorf("Unable to obtain bucket 'workflows' - %s", err)
+		}
+
+		if definition == "" {
+			return b.Delete([]byte(name))
+		}
+
+		if err := b.Put([]byte(name), []byte(definition)); err != nil {
+			return fmt.Errorf("Unable to save workflow to bucket 'workflows' - %s", err)
+		}
+
+		return nil
+	})
+}
+
+func (g *GatewayT) listWorkflows() error {
+	workflows, err := g.Workflows()
+	if err != nil {
+		return err
+	}
+
+	if g.output == outputJSON {
+		return g.renderJSON(workflows)
+	}
+
+	names := []string{}
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(g.Stdout, "%s = %s\n", name, g.redactDefinition(name, workflows[name]))
+	}
+
+	return nil
+}
+
+// runWorkflow runs steps of the workflow one after another as child
+// processes, and reports the outcome of every step at the end.
+func (g *GatewayT) runWorkflow(name, definition string, args []string) error {
+	if len(args) > 0 {
+		return g.showUsage(name)
+	}
+
+	for _, running := range g.runningWorkflows {
+		if running == name {
+			return fmt.Errorf("Workflow '%s' runs itself", name)
+		}
+	}
+
+	workflow, err := parseWorkflow(name, definition)
+	if err != nil {
+		return err
+	}
+
+	if err := g.redactSteps(workflow); err != nil {
+		return err
+	}
+
+	if err := g.checkSteps(workflow); err != nil {
+		return err
+	}
+
+	g.runningWorkflows = append(g.runningWorkflows, name)
+	defer func() { g.runningWorkflows = g.runningWorkflows[:len(g.runningWorkflows)-1] }()
+
+	// Steps are run as child processes, so that the workflow survives them.
+	execFn := g.ExecFn
+	g.ExecFn = g.childExec
+	defer func() { g.ExecFn = execFn }()
+
+	failed := 0
+	succeeded := true
+	for i, step := range workflow.Steps {
+		if step.Op == "&&" && !succeeded {
+			step.Status = stepSkipped
+			continue
+		}
+
+		if err := g.context().Err(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(g.Stdout, "==> [%d/%d] %s\n", i+1, len(workflow.Steps), step)
+
+		step.Status = stepOK
+		if err := g.run(append([]string{g.Name}, step.Args...)); err != nil {
+			step.Status = stepFailed
+			step.Details = g.redact(err.Error())
+			failed++
+		}
+		succeeded = step.Status == stepOK
+	}
+
+	view := &workflowSummaryView{Workflow: workflow}
+	if err := view.render(g.template("workflowSummary"), g.Stdout); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return renderedError{fmt.Errorf("Workflow '%s' failed at %d step(s)", name, failed)}
+	}
+
+	return nil
+}
+
+// redactSteps hides sensitive arguments of steps, the way they are hidden
+// for plugins steps run.
+func (g *GatewayT) redactSteps(workflow *workflowT) error {
+	return g.Store.View(func(tx Tx) error {
+		for _, step := range workflow.Steps {
+			p, err := resolvePlugin(tx, step.Args[0])
+			if err != nil {
+				p = &pluginT{}
+			}
+
+			step.Shown = strings.Join(append([]string{step.Args[0]}, g.redactArgs(p, step.Args[1:])...), " ")
+		}
+
+		return nil
+	})
+}
+
+// redactDefinition hides sensitive arguments of workflow definition. It is
+// shown as is, unless there is something to hide.
+func (g *GatewayT) redactDefinition(name, definition string) string {
+	workflow, err := parseWorkflow(name, definition)
+	if err != nil || g.redactSteps(workflow) != nil {
+		return g.redact(definition)
+	}
+
+	parts := []string{}
+	changed := false
+	for i, step := range workflow.Steps {
+		if i > 0 {
+			parts = append(parts, step.Op)
+		}
+
+		parts = append(parts, step.Shown)
+		changed = changed || step.Shown != strings.Join(step.Args, " ")
+	}
+
+	if !changed {
+		return g.redact(definition)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// checkSteps makes sure that every step runs something, before any of them
+// is run, since unknown commands only print help.
+func (g *GatewayT) checkSteps(workflow *workflowT) error {
+	workflows, err := g.Workflows()
+	if err != nil {
+		return err
+	}
+
+	return g.Store.View(func(tx Tx) error {
+		for _, step := range workflow.Steps {
+			command := step.Args[0]
+
+			if _, ok := builtinHandlers[command]; ok {
+				continue
+			}
+
+			if _, ok := workflows[command]; ok {
+				continue
+			}
+
+			if _, err := resolvePlugin(tx, command); err != nil {
+				return fmt.Errorf("Unable to run workflow '%s', step '%s' - %s", workflow.Name, step, err)
+			}
+		}
+
+		return nil
+	})
+}